@@ -0,0 +1,148 @@
+package main
+
+import (
+	"container/list"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAutoLeaves is the number of leaf certificates retained by autoCA: the least recently used ones are
+// discarded first, so that clients requesting arbitrary host names cannot exhaust memory.
+const maxAutoLeaves = 256
+
+// autoCA is an in-memory certificate authority issuing leaf certificates on the fly,
+// for any host name requested by TLS clients (SNI).
+type autoCA struct {
+	cert   *x509.Certificate
+	key    crypto.Signer
+	pem    []byte
+	leaves map[string]*list.Element
+	recent *list.List
+	lock   sync.Mutex
+}
+
+type autoLeaf struct {
+	key  string
+	cert *tls.Certificate
+}
+
+func newAutoCA() (*autoCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "imPOSTer CA", Organization: []string{"imPOSTer"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &autoCA{
+		cert:   cert,
+		key:    key,
+		pem:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		leaves: make(map[string]*list.Element),
+		recent: list.New(),
+	}, nil
+}
+
+// getCertificate implements the GetCertificate callback of tls.Config: leaf certificates are issued
+// for the requested server name or, without SNI, for localhost and the local address of the connection.
+func (ca *autoCA) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	var ip net.IP
+	if name == "" && hello.Conn != nil {
+		if a, ok := hello.Conn.LocalAddr().(*net.TCPAddr); ok {
+			ip = a.IP
+		}
+	}
+	key := name
+	if key == "" {
+		key = ip.String()
+	}
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if e, ok := ca.leaves[key]; ok {
+		ca.recent.MoveToFront(e)
+		return e.Value.(*autoLeaf).cert, nil
+	}
+	c, err := ca.issue(name, ip)
+	if err != nil {
+		return nil, fmt.Errorf("could not issue a certificate for '%s': %v", key, err)
+	}
+	ca.leaves[key] = ca.recent.PushFront(&autoLeaf{key: key, cert: c})
+	if ca.recent.Len() > maxAutoLeaves {
+		e := ca.recent.Back()
+		ca.recent.Remove(e)
+		delete(ca.leaves, e.Value.(*autoLeaf).key)
+	}
+	return c, nil
+}
+
+func (ca *autoCA) issue(name string, ip net.IP) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     ca.cert.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if name != "" {
+		template.Subject = pkix.Name{CommonName: name}
+		template.DNSNames = []string{name}
+	} else {
+		template.Subject = pkix.Name{CommonName: "localhost"}
+		template.DNSNames = []string{"localhost"}
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+		if ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key}, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"testing"
+)
+
+func handshake(t *testing.T, ca *autoCA, serverName string) *x509.Certificate {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: ca.getCertificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.(*tls.Conn).Handshake()
+	}()
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca.pem) {
+		t.Fatalf("could not decode the PEM encoded certificate authority")
+	}
+	// the leaf is verified against the PEM encoded certificate authority, as clients would do
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: roots, ServerName: serverName})
+	if err != nil {
+		t.Fatalf("could not complete the TLS handshake with server name '%s': %v", serverName, err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0]
+}
+
+func TestAutoCAHandshake(t *testing.T) {
+	ca, err := newAutoCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf := handshake(t, ca, "api.example.com"); len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "api.example.com" {
+		t.Errorf("expected a certificate for 'api.example.com'; got %v instead", leaf.DNSNames)
+	}
+	// without SNI, since IP addresses are not sent as server names
+	leaf := handshake(t, ca, "127.0.0.1")
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Errorf("expected a certificate for 'localhost': %v", err)
+	}
+}
+
+func TestAutoCALeavesLimit(t *testing.T) {
+	ca, err := newAutoCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := ca.getCertificate(&tls.ClientHelloInfo{ServerName: "host0.test"})
+	for i := 1; i <= maxAutoLeaves; i++ {
+		if _, err := ca.getCertificate(&tls.ClientHelloInfo{ServerName: fmt.Sprintf("host%d.test", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l := len(ca.leaves); l != maxAutoLeaves || ca.recent.Len() != maxAutoLeaves {
+		t.Errorf("expected %d certificates to be retained; got %d instead", maxAutoLeaves, l)
+	}
+	if c, _ := ca.getCertificate(&tls.ClientHelloInfo{ServerName: "host0.test"}); c == first {
+		t.Errorf("expected the least recently used certificate to be discarded")
+	}
+}
+
+func TestTLSAutoCAFileRequiresTLSAuto(t *testing.T) {
+	s := &startOpts{tlsAutoCAFile: "ca.pem"}
+	if _, err := s.buildTLSConfig(); err == nil {
+		t.Errorf("an error was expected for -tls-auto-ca-file without -tls-auto")
+	}
+}
@@ -1,127 +1,302 @@
 package cfg
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"path/filepath"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/mitchellh/mapstructure"
 	"github.com/naighes/imposter/functions"
-	"gopkg.in/yaml.v2"
 )
 
 // Config represents an imPOSTer configuration.
 // A set of rule expressions can be defined dy Defs field.
+// Other configuration files can be pulled in by the Include field.
+// Requests matching no rule are handled by DefaultResponse, proxied to Upstream or, in Strict mode,
+// answered by a 501 status code; at most one of them can be specified.
+// Middleware lists the middleware wrapping the router, from the outermost one, while Cors defines
+// the Cross-Origin Resource Sharing policy.
+// Listeners declares the endpoints requests are accepted from, in place of the port of the start command.
+// Requests addressed to any of the VirtualHosts are served by their own rules.
+// GRPC declares the gRPC services whose calls are decoded, so that they can be matched by rules.
 type Config struct {
-	Defs []*MatchDef            `json:"pattern_list" yaml:"pattern_list"`
-	Vars map[string]interface{} `json:"vars" yaml:"vars"`
+	Defs            []*MatchDef            `json:"pattern_list" yaml:"pattern_list"`
+	Vars            map[string]interface{} `json:"vars" yaml:"vars"`
+	Include         []string               `json:"include" yaml:"include"`
+	DefaultResponse interface{}            `json:"default_response" yaml:"default_response"`
+	Upstream        string                 `json:"upstream" yaml:"upstream"`
+	Strict          bool                   `json:"strict" yaml:"strict"`
+	Middleware      []string               `json:"middleware" yaml:"middleware"`
+	Cors            *CorsConfig            `json:"cors" yaml:"cors"`
+	Listeners       []*ListenerConfig      `json:"listeners" yaml:"listeners"`
+	VirtualHosts    []*VirtualHost         `json:"virtual_hosts" yaml:"virtual_hosts"`
+	GRPC            *GRPCConfig            `json:"grpc" yaml:"grpc"`
+
+	varPositions map[string]Position
+	sources      []*source
+	sections     map[string]*source
 }
 
 // MatchDef represents a single rule expression.
+// A rule can be identified by Name, while rules with a higher Priority are tested first and Tags allow
+// to load a subset of rules.
 // The RuleExpression field wraps a boolean expression every incoming HTTP request is matched against.
-// How a matching rule expression should be managed is defined by the Response object.
+// How a matching rule expression should be managed is defined by the Response object or, alternatively,
+// by a list of Responses picked according to ResponseMode.
 type MatchDef struct {
-	RuleExpression string        `json:"rule_expression" yaml:"rule_expression"`
-	Latency        time.Duration `json:"latency" yaml:"latency"`
-	Response       interface{}   `json:"response" yaml:"response"`
+	Name           string         `json:"name" yaml:"name"`
+	Priority       int            `json:"priority" yaml:"priority"`
+	Tags           []string       `json:"tags" yaml:"tags"`
+	RuleExpression string         `json:"rule_expression" yaml:"rule_expression"`
+	Latency        time.Duration  `json:"latency" yaml:"latency"`
+	Response       interface{}    `json:"response" yaml:"response"`
+	Responses      []*WeightedRsp `json:"responses" yaml:"responses"`
+	ResponseMode   ResponseMode   `json:"response_mode" yaml:"response_mode"`
+	StickOnLast    bool           `json:"stick_on_last" yaml:"stick_on_last"`
+
+	src   *source
+	index int
+	// scope is the path of the enclosing virtual host, if any
+	scope []interface{}
+}
+
+// Source returns the position of the rule within its originating configuration file.
+func (def *MatchDef) Source() Position {
+	return def.src.position(def.path()...)
+}
+
+func (def *MatchDef) path(path ...interface{}) []interface{} {
+	r := append([]interface{}{}, def.scope...)
+	r = append(r, "pattern_list", def.index)
+	return append(r, path...)
+}
+
+// VarPosition returns the position of the variable definition with the specified name.
+func (c *Config) VarPosition(name string) (Position, bool) {
+	p, ok := c.varPositions[name]
+	return p, ok
 }
 
 // MatchRsp is the fully structured version of a Response object.
 // Body represents the payload to be returned and it can be an expression as well.
 // Headers is a collection of HTTP headers to be returned and each entry can be an expression.
-// StatusCode represents the resulting HTTP status code and it MUST be an expression:
+// StatusCode represents the resulting HTTP status code and it MUST be an expression.
+// Alternatively, the payload can be rendered by a Go template, either inline (Template) or read from a file (TemplateFile):
 //		rsp := MatchRsp{Body: "some content", StatusCode: `${200}`}
 type MatchRsp struct {
-	Body       string                 `mapstructure:"body"`
-	Headers    map[string]interface{} `mapstructure:"headers"`
-	StatusCode string                 `mapstructure:"status_code"`
+	Body         string                 `mapstructure:"body"`
+	Headers      map[string]interface{} `mapstructure:"headers"`
+	StatusCode   string                 `mapstructure:"status_code"`
+	Template     string                 `mapstructure:"template"`
+	TemplateFile string                 `mapstructure:"template_file"`
+}
+
+// ReadConfig takes a path as an input and parses its content to build the imPOSTer configuration.
+// The path can point to a single file, a directory or a glob pattern: in the latter cases all matching
+// files are merged together in lexical order.
+func ReadConfig(configFile string) (*Config, error) {
+	return ReadConfigFormat(configFile, "")
 }
 
-func parseConfig(j []byte) (*Config, error) {
-	var r *Config
-	err := json.Unmarshal(j, &r)
+// ReadConfigFormat works like ReadConfig, but the syntax of the specified files is explicitly selected
+// by format rather than detected by their extension. Included files are always detected by extension.
+func ReadConfigFormat(configFile string, format Format) (*Config, error) {
+	if configFile == "" {
+		return &Config{}, nil
+	}
+	files, err := resolveFiles(configFile)
 	if err != nil {
-		return parseYaml(j)
+		return nil, err
+	}
+	l := newLoader()
+	for _, file := range files {
+		if err := l.load(file, format, nil); err != nil {
+			return nil, err
+		}
 	}
-	return r, nil
+	return l.config, nil
 }
 
-func parseYaml(j []byte) (*Config, error) {
-	var r *Config
-	err := yaml.Unmarshal(j, &r)
-	if err != nil {
+// ParseConfig builds the imPOSTer configuration from the content of the specified file, which is
+// not required to be saved yet (e.g. a document being edited); included files are read from disk.
+func ParseConfig(configFile string, raw []byte) (*Config, error) {
+	l := newLoader()
+	if err := l.loadRaw(configFile, detectFormat(configFile), raw, nil); err != nil {
 		return nil, err
 	}
-	return r, nil
+	return l.config, nil
 }
 
-// ReadConfig takes a path as an input and parses its content to build the imPOSTer configuration.
-func ReadConfig(configFile string) (*Config, error) {
-	if configFile == "" {
-		return &Config{}, nil
+// FilterTags keeps just the rules labeled by at least one of the specified tags, including the ones
+// of virtual hosts. All rules are kept whether no tags are specified.
+func (c *Config) FilterTags(tags []string) {
+	if len(tags) == 0 {
+		return
 	}
-	var err error
-	var configPath string
-	var rawConfig []byte
-	var config *Config
-	if configPath, err = filepath.Abs(configFile); err == nil {
-		if rawConfig, err = ioutil.ReadFile(configPath); err == nil {
-			if config, err = parseConfig(rawConfig); err == nil {
-				return config, nil
+	c.Defs = filterTags(c.Defs, tags)
+	hosts := make([]*VirtualHost, len(c.VirtualHosts))
+	for i, vh := range c.VirtualHosts {
+		h := *vh
+		h.Defs = filterTags(vh.Defs, tags)
+		hosts[i] = &h
+	}
+	c.VirtualHosts = hosts
+}
+
+func filterTags(defs []*MatchDef, tags []string) []*MatchDef {
+	var r []*MatchDef
+	for _, def := range defs {
+		if def.hasAnyTag(tags) {
+			r = append(r, def)
+		}
+	}
+	return r
+}
+
+func (def *MatchDef) hasAnyTag(tags []string) bool {
+	for _, t := range def.Tags {
+		for _, e := range tags {
+			if t == e {
+				return true
 			}
 		}
 	}
-	return nil, err
+	return false
 }
 
 // Validate method parses the current expression trying to catch potential evaluation errors.
+// Every error is reported along with the field it was raised by and its position in the configuration file.
 // An empty array is returned whether no errors were found.
-func (def *MatchDef) Validate(parse functions.ExpressionParser, vars map[string]interface{}) []string {
-	var r []string
+func (def *MatchDef) Validate(parse functions.ExpressionParser, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
 	if err := validateRuleExpression(def.RuleExpression, vars); err != nil {
-		r = append(r, fmt.Sprintf("%v", err))
+		r = append(r, def.diagnostic(err, "rule_expression"))
 	}
-	var rsp MatchRsp
-	err := mapstructure.Decode(def.Response, &rsp)
-	if err == nil {
-		rr := rsp.validate(parse, vars)
-		r = append(r, rr...)
-	} else {
-		body, _ := def.Response.(string)
-		err := validateComputedBody(body, vars)
-		if err != nil {
-			r = append(r, fmt.Sprintf("%v", err))
-		}
+	if def.Response != nil && def.Responses != nil {
+		return append(r, def.diagnostic(fmt.Errorf("'response' and 'responses' are mutually exclusive"), "responses"))
 	}
-	return r
+	if def.Responses != nil {
+		return append(r, def.validateResponses(parse, vars)...)
+	}
+	if def.Response == nil {
+		return append(r, def.diagnostic(fmt.Errorf("either 'response' or 'responses' is required")))
+	}
+	return append(r, validateResponse(def.diagnostic, def.Response, []interface{}{"response"}, parse, vars)...)
 }
 
-func (rsp *MatchRsp) validate(parse functions.ExpressionParser, vars map[string]interface{}) []string {
-	var r []string
-	_, err := validateEvaluation(rsp.Body, vars)
+// Validate checks the structure of the configuration and then, whether it is valid, its rules along with
+// every other section, trying to catch potential evaluation errors.
+// An empty array is returned whether no errors were found.
+func (c *Config) Validate(parse functions.ExpressionParser) []*Diagnostic {
+	r := c.ValidateStructure()
+	if len(r) > 0 {
+		return r
+	}
+	vars := c.Vars
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	for _, def := range c.Defs {
+		r = append(r, def.Validate(parse, vars)...)
+	}
+	r = append(r, c.ValidateFallback(parse, vars)...)
+	r = append(r, c.ValidateMiddleware()...)
+	r = append(r, c.ValidateCors()...)
+	r = append(r, c.ValidateListeners()...)
+	r = append(r, c.ValidateGRPC()...)
+	return append(r, c.ValidateVirtualHosts(parse, vars)...)
+}
+
+// locator builds a Diagnostic for the field identified by path.
+type locator func(err error, path ...interface{}) *Diagnostic
+
+// validateResponse validates the response object o, which is identified by path.
+func validateResponse(loc locator, o interface{}, path []interface{}, parse functions.ExpressionParser, vars map[string]interface{}) []*Diagnostic {
+	script, err := DecodeScriptRsp(o)
 	if err != nil {
-		r = append(r, fmt.Sprintf("%v", err))
+		return []*Diagnostic{loc(err, path...)}
+	}
+	if script != nil {
+		return script.validate(loc, path)
 	}
-	_, err = rsp.ParseHeaders(parse)
+	grpc, err := DecodeGRPCRsp(o)
 	if err != nil {
-		if errors, ok := err.(*multierror.Error); ok {
-			for err := range errors.Errors {
-				r = append(r, fmt.Sprintf("%v", err))
+		return []*Diagnostic{loc(err, field(path, "grpc")...)}
+	}
+	if grpc != nil {
+		return nil
+	}
+	ws, err := DecodeWebSocketRsp(o)
+	if err != nil {
+		return []*Diagnostic{loc(err, field(path, "websocket")...)}
+	}
+	if ws != nil {
+		return ws.validate(loc, path, vars)
+	}
+	sse, err := DecodeSSERsp(o)
+	if err != nil {
+		return []*Diagnostic{loc(err, field(path, "sse")...)}
+	}
+	if sse != nil {
+		return sse.validate(loc, path, vars)
+	}
+	var rsp MatchRsp
+	if err := mapstructure.Decode(o, &rsp); err == nil {
+		return rsp.validate(loc, path, parse, vars)
+	}
+	body, _ := o.(string)
+	if err := validateComputedBody(body, vars); err != nil {
+		return []*Diagnostic{loc(err, path...)}
+	}
+	return nil
+}
+
+// field returns the path of a field nested into the element identified by path.
+func field(path []interface{}, a ...interface{}) []interface{} {
+	return append(path[:len(path):len(path)], a...)
+}
+
+func (rsp *MatchRsp) validate(loc locator, path []interface{}, parse functions.ExpressionParser, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
+	if rsp.HasTemplate() {
+		if _, err := rsp.ParseTemplate(); err != nil {
+			name := "template"
+			if rsp.TemplateFile != "" {
+				name = "template_file"
 			}
+			r = append(r, loc(err, field(path, name)...))
 		}
+	} else if _, err := validateEvaluation(rsp.Body, vars); err != nil {
+		r = append(r, loc(err, field(path, "body")...))
 	}
-	err = validateStatusCode(rsp.StatusCode, vars)
-	if err != nil {
-		r = append(r, fmt.Sprintf("%v", err))
+	keys := make([]string, 0, len(rsp.Headers))
+	for k := range rsp.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateHeader(rsp.Headers[k], parse); err != nil {
+			r = append(r, loc(err, field(path, "headers", k)...))
+		}
+	}
+	if err := validateStatusCode(rsp.StatusCode, vars); err != nil {
+		r = append(r, loc(err, field(path, "status_code")...))
 	}
 	return r
 }
 
+func validateHeader(v interface{}, parse functions.ExpressionParser) error {
+	header, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("expected a value of type 'string'; got '%v' instead", reflect.TypeOf(v))
+	}
+	_, err := parse(header)
+	return err
+}
+
 func validateStatusCode(expression string, vars map[string]interface{}) error {
 	if expression == "" {
 		return nil
@@ -1,8 +1,16 @@
 package cfg
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/naighes/imposter/functions"
 )
 
@@ -68,3 +76,862 @@ func TestStatusCodeTypeMismatch(t *testing.T) {
 		return
 	}
 }
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("IMPOSTER_TEST_PORT", "8081")
+	defer os.Unsetenv("IMPOSTER_TEST_PORT")
+	raw := []byte(`some_link: http://${IMPOSTER_TEST_HOST:-localhost}:${IMPOSTER_TEST_PORT}/${var("a")}`)
+	r, err := ExpandEnv(raw)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	const expected = `some_link: http://localhost:8081/${var("a")}`
+	if s := string(r); s != expected {
+		t.Errorf("expected '%s'; got '%s' instead", expected, s)
+		return
+	}
+}
+
+func TestExpandEnvNotSet(t *testing.T) {
+	os.Unsetenv("IMPOSTER_TEST_HOST")
+	if _, err := ExpandEnv([]byte(`host: ${IMPOSTER_TEST_HOST}`)); err == nil {
+		t.Errorf("an error was expected for a missing environment variable")
+		return
+	}
+}
+
+func TestMergeVars(t *testing.T) {
+	config := Config{Vars: map[string]interface{}{"host": "localhost", "port": "8080"}}
+	config.MergeVars(map[string]interface{}{"port": "9090"})
+	if v := config.Vars["port"]; v != "9090" {
+		t.Errorf("expected overridden value '9090'; got '%v' instead", v)
+		return
+	}
+	if v := config.Vars["host"]; v != "localhost" {
+		t.Errorf("expected value 'localhost'; got '%v' instead", v)
+		return
+	}
+}
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "imposter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadConfigDirectory(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"b.yaml": "pattern_list:\n- rule_expression: ${true}\n  response: b\n",
+		"a.json": `{"pattern_list": [{"rule_expression": "${false}", "response": "a"}], "vars": {"x": "1"}}`,
+		"c.txt":  "not a configuration file",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if l := len(config.Defs); l != 2 {
+		t.Errorf("expected %d rule(s); got %d instead", 2, l)
+		return
+	}
+	if r := config.Defs[0].Response; r != "a" {
+		t.Errorf("expected rules sorted by file name; got '%v' as first response", r)
+		return
+	}
+	if p := config.Defs[1].Source(); p.File != filepath.Join(dir, "b.yaml") || p.Line != 2 {
+		t.Errorf("unexpected rule position '%s'", p)
+		return
+	}
+}
+
+func TestReadConfigSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{"fallback", "strict: true\n", "the handling of unmatched requests is already defined by"},
+		{"middleware", "middleware: [logging]\n", "middleware are already defined by"},
+		{"cors", "cors:\n  allowed_origins: ['*']\n", "the CORS policy is already defined by"},
+		{"listeners", "listeners:\n- address: ':8080'\n", "listeners are already defined by"},
+		{"grpc", "grpc:\n  proto_files: [a.proto]\n", "gRPC services are already defined by"},
+	}
+	for _, test := range tests {
+		dir := writeConfigFiles(t, map[string]string{"a.yaml": test.content, "b.yaml": test.content})
+		config, err := ReadConfig(filepath.Join(dir, "a.yaml"))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if config.sections[test.name] == nil || config.sections[test.name].file != filepath.Join(dir, "a.yaml") {
+			t.Errorf("%s: expected the defining file to be tracked", test.name)
+		}
+		_, err = ReadConfig(dir)
+		if err == nil || !strings.Contains(err.Error(), test.message) || !strings.Contains(err.Error(), "a.yaml") {
+			t.Errorf("%s: expected '%s a.yaml'; got '%v' instead", test.name, test.message, err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReadConfigInclude(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yaml":         "include:\n- teams/*.yaml\npattern_list:\n- rule_expression: ${true}\n  response: default\n",
+		"teams/orders.yaml": "pattern_list:\n- rule_expression: ${false}\n  response: orders\n",
+		"teams/users.yaml":  "include:\n- ../main.yaml\npattern_list:\n- rule_expression: ${false}\n  response: users\n",
+	})
+	defer os.RemoveAll(dir)
+	_, err := ReadConfig(filepath.Join(dir, "main.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected an include cycle error; got '%v' instead", err)
+		return
+	}
+	ioutil.WriteFile(filepath.Join(dir, "teams/users.yaml"), []byte("pattern_list:\n- rule_expression: ${false}\n  response: users\n"), 0644)
+	config, err := ReadConfig(filepath.Join(dir, "main.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []string{"orders", "users", "default"}
+	if l := len(config.Defs); l != len(expected) {
+		t.Errorf("expected %d rule(s); got %d instead", len(expected), l)
+		return
+	}
+	for i, e := range expected {
+		if r := config.Defs[i].Response; r != e {
+			t.Errorf("expected response '%s' at index %d; got '%v' instead", e, i, r)
+			return
+		}
+	}
+}
+
+func TestReadConfigVarsConflict(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "vars:\n  host: localhost\n  port: 8080\n",
+		"b.yaml": "vars:\n  host: localhost\n\n  port: 9090\n",
+	})
+	defer os.RemoveAll(dir)
+	_, err := ReadConfig(filepath.Join(dir, "*.yaml"))
+	if err == nil {
+		t.Errorf("a conflict error was expected")
+		return
+	}
+	expected := fmt.Sprintf("%s:4:9: variable 'port' conflicts with the one defined at %s:3:9", filepath.Join(dir, "b.yaml"), filepath.Join(dir, "a.yaml"))
+	if err.Error() != expected {
+		t.Errorf("expected error '%s'; got '%v' instead", expected, err)
+		return
+	}
+}
+
+func TestValidationDiagnostics(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "pattern_list:\n- rule_expression: ${true}\n  response:\n    headers:\n      Content-Type: ${www}\n    status_code: ${\"200\"}\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.Defs[0].Validate(functions.ParseExpression, make(map[string]interface{}))
+	expected := []struct {
+		field  string
+		line   int
+		column int
+	}{
+		{"response.headers.Content-Type", 5, 21},
+		{"response.status_code", 6, 18},
+	}
+	if l := len(errors); l != len(expected) {
+		t.Errorf("expected %d error(s); got %d instead", len(expected), l)
+		return
+	}
+	for i, e := range expected {
+		d := errors[i]
+		if d.Field != e.field || d.Line != e.line || d.Column != e.column || d.Rule != 0 {
+			t.Errorf("expected error on '%s' at %d:%d; got '%s' at %d:%d instead", e.field, e.line, e.column, d.Field, d.Line, d.Column)
+			return
+		}
+	}
+}
+
+func TestValidateStructure(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "pattern_list:\n- rule_expression: ${true}\n  response:\n    headers:\n      X-Count: 5\n    status-code: ${200}\n- rule_expression: ${true}\n  latency: -5\n  response: hello\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateStructure()
+	expected := []struct {
+		rule  int
+		field string
+		line  int
+	}{
+		{0, "response.headers.X-Count", 5},
+		{0, "response", 6},
+		{1, "latency", 8},
+		{1, "response", 9},
+	}
+	if l := len(errors); l != len(expected) {
+		t.Errorf("expected %d error(s); got %d instead: %v", len(expected), l, errors)
+		return
+	}
+	for i, e := range expected {
+		d := errors[i]
+		if d.Rule != e.rule || d.Field != e.field || d.Line != e.line {
+			t.Errorf("expected error on rule %d field '%s' at line %d; got '%s' instead", e.rule, e.field, e.line, d)
+			return
+		}
+	}
+	if m := errors[1].Message; m != "unknown key 'status-code': did you mean 'status_code'?" {
+		t.Errorf("unexpected message '%s'", m)
+		return
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	b, err := JSONSchema()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var s map[string]interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := s["$defs"].(map[string]interface{})["match_rsp"]; !ok {
+		t.Errorf("expected a definition for 'match_rsp'")
+		return
+	}
+}
+
+func TestStructuralDecodingError(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "pattern_list:\n- rule_expression: ${true}\n  latency: fast\n  response: ${link(\"http://fak.eurl\")}\n",
+	})
+	defer os.RemoveAll(dir)
+	_, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	e, ok := err.(*DiagnosticError)
+	if !ok {
+		t.Errorf("expected an error of type '*DiagnosticError'; got '%v' instead", err)
+		return
+	}
+	if l := len(e.Diagnostics); l != 1 {
+		t.Errorf("expected %d error(s); got %d instead", 1, l)
+		return
+	}
+	if d := e.Diagnostics[0]; d.Field != "latency" || d.Line != 3 {
+		t.Errorf("expected error on field 'latency' at line 3; got '%s' instead", d)
+		return
+	}
+}
+
+func TestReadConfigFormats(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.json": `{"pattern_list": [{"rule_expression": "${true}", "latency": 10, "response": {"body": "json"}}]}`,
+		"b.yaml": "pattern_list:\n- rule_expression: ${true}\n  response:\n    body: yaml\n",
+		"c.toml": "[[pattern_list]]\nrule_expression = \"${true}\"\nlatency = 10\n[pattern_list.response]\nbody = \"toml\"\n",
+		"d.hcl":  "pattern_list {\n  rule_expression = \"${true}\"\n  latency = 10\n  response {\n    body = \"hcl\"\n  }\n}\nvars {\n  a = \"b\"\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []string{"json", "yaml", "toml", "hcl"}
+	if l := len(config.Defs); l != len(expected) {
+		t.Errorf("expected %d rule(s); got %d instead", len(expected), l)
+		return
+	}
+	for i, e := range expected {
+		var rsp MatchRsp
+		if err := mapstructure.Decode(config.Defs[i].Response, &rsp); err != nil || rsp.Body != e {
+			t.Errorf("expected body '%s'; got '%v' instead", e, config.Defs[i].Response)
+			return
+		}
+	}
+	if v := config.Vars["a"]; v != "b" {
+		t.Errorf("expected variable 'a' to be 'b'; got '%v' instead", v)
+		return
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	files := map[string]string{
+		"a.json": "{\n  \"pattern_list\": [],\n  \"varz\": {}\n}",
+		"b.yaml": "pattern_list: []\nvarz: {}\n",
+		"c.toml": "pattern_list = []\n[varz]\n",
+		"d.hcl":  "varz {\n}\n",
+	}
+	dir := writeConfigFiles(t, files)
+	defer os.RemoveAll(dir)
+	for name := range files {
+		_, err := ReadConfig(filepath.Join(dir, name))
+		if err == nil || !strings.Contains(err.Error(), "varz") {
+			t.Errorf("expected an unknown key error for '%s'; got '%v' instead", name, err)
+			return
+		}
+	}
+	dir = writeConfigFiles(t, map[string]string{
+		"e.toml": "[[pattern_list]]\nrule_expression = \"${true}\"\n[pattern_list.response]\nstatus-code = 200\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "e.toml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateStructure()
+	if len(errors) != 1 || !strings.Contains(errors[0].String(), "status-code") {
+		t.Errorf("expected a single structural error on key 'status-code'; got %v instead", errors)
+	}
+}
+
+func TestExplicitFormat(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config": "{\n  \"pattern_list\": [\n    {\"rule_expression\": \"${true}\",}\n  ]\n}",
+	})
+	defer os.RemoveAll(dir)
+	_, err := ReadConfigFormat(filepath.Join(dir, "config"), FormatJSON)
+	if err == nil || !strings.Contains(err.Error(), "line 3, column 35") {
+		t.Errorf("expected a JSON syntax error at line 3, column 35; got '%v' instead", err)
+		return
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Errorf("an error was expected for an unsupported format")
+		return
+	}
+}
+
+func TestScriptResponse(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "pattern_list:\n- rule_expression: ${true}\n  response:\n    script: \"return {body: 'ok'}\"\n    timeout: 200\n- rule_expression: ${true}\n  response:\n    script: 'return {'\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if errors := config.ValidateStructure(); len(errors) > 0 {
+		t.Errorf("expected no structural errors; got %v instead", errors)
+		return
+	}
+	rsp, err := DecodeScriptRsp(config.Defs[0].Response)
+	if err != nil || rsp == nil {
+		t.Errorf("expected a scripted response; got %v instead", err)
+		return
+	}
+	if rsp.Timeout != 200 {
+		t.Errorf("expected a timeout of 200; got %d instead", rsp.Timeout)
+	}
+	if errors := config.Defs[0].Validate(functions.ParseExpression, nil); len(errors) > 0 {
+		t.Errorf("expected no errors; got %v instead", errors)
+	}
+	errors := config.Defs[1].Validate(functions.ParseExpression, nil)
+	if len(errors) != 1 || errors[0].Field != "response.script" || errors[0].Line != 8 {
+		t.Errorf("expected a single error on field 'response.script' at line 8; got %v instead", errors)
+	}
+}
+
+func TestTemplateResponse(t *testing.T) {
+	def := &MatchDef{RuleExpression: `${true}`, Response: &MatchRsp{Template: `{{uuid}} {{now | date_add "24h" | date_format "2006-01-02"}}`}}
+	if errors := def.Validate(functions.ParseExpression, nil); len(errors) > 0 {
+		t.Errorf("expected no errors; got %v instead", errors)
+		return
+	}
+	def = &MatchDef{RuleExpression: `${true}`, Response: &MatchRsp{Template: `{{unknown}}`}}
+	errors := def.Validate(functions.ParseExpression, nil)
+	if len(errors) != 1 || errors[0].Field != "response.template" {
+		t.Errorf("expected a single error on field 'response.template'; got %v instead", errors)
+	}
+	def = &MatchDef{RuleExpression: `${true}`, Response: &MatchRsp{Body: "hello", Template: "hello"}}
+	if errors := def.Validate(functions.ParseExpression, nil); len(errors) != 1 {
+		t.Errorf("expected body and template to be mutually exclusive")
+	}
+}
+
+func TestResponses(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "pattern_list:\n- rule_expression: ${true}\n  response_mode: weighted\n  responses:\n  - response: ${redirect(\"http://example.com\", 301)}\n    weight: 0\n  - response:\n      status_code: ${200}\n    weight: 0\n- rule_expression: ${true}\n  response_mode: shuffle\n  responses:\n  - response: hello\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateStructure()
+	if len(errors) != 2 || errors[0].Rule != 1 || errors[0].Field != "response_mode" || errors[1].Field != "responses.0.response" {
+		t.Errorf("expected structural errors on fields 'response_mode' and 'responses.0.response'; got %v instead", errors)
+		return
+	}
+	errors = config.Defs[0].Validate(functions.ParseExpression, nil)
+	if len(errors) != 1 || errors[0].Field != "responses" {
+		t.Errorf("expected a single error on field 'responses'; got %v instead", errors)
+		return
+	}
+	errors = config.Defs[1].Validate(functions.ParseExpression, nil)
+	if len(errors) != 2 || errors[0].Field != "response_mode" || errors[1].Field != "responses.0.response" || errors[1].Line != 13 {
+		t.Errorf("expected errors on fields 'response_mode' and 'responses.0.response'; got %v instead", errors)
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	config := &Config{Defs: []*MatchDef{
+		{Name: "a", Tags: []string{"users"}},
+		{Name: "b", Tags: []string{"orders", "slow"}},
+		{Name: "c"},
+	}}
+	config.FilterTags([]string{"slow", "users"})
+	if l := len(config.Defs); l != 2 || config.Defs[0].Name != "a" || config.Defs[1].Name != "b" {
+		t.Errorf("expected rules 'a' and 'b'; got %d rule(s) instead", l)
+	}
+}
+
+func TestValidateFallback(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "default_response:\n  status_code: ${404}\nupstream: http://localhost:3000\n",
+		"b.yaml": "strict: true\n",
+		"c.yaml": "default_response:\n  status_code: \"404\"\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "a.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateFallback(functions.ParseExpression, nil)
+	if len(errors) != 1 || errors[0].Field != "upstream" || errors[0].Line != 3 {
+		t.Errorf("expected a single error on field 'upstream' at line 3; got %v instead", errors)
+		return
+	}
+	if _, err := ReadConfig(filepath.Join(dir, "*.yaml")); err == nil {
+		t.Errorf("expected an error for unmatched requests being handled by multiple files")
+		return
+	}
+	config, err = ReadConfig(filepath.Join(dir, "c.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors = config.ValidateFallback(functions.ParseExpression, nil)
+	if len(errors) != 1 || errors[0].Field != "default_response.status_code" {
+		t.Errorf("expected a single error on field 'default_response.status_code'; got %v instead", errors)
+	}
+}
+
+func TestValidateMiddleware(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "middleware:\n  - cors\n  - auth\n  - cors\n",
+		"b.yaml": "middleware: []\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "a.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateMiddleware()
+	if len(errors) != 2 || errors[0].Field != "middleware.1" || errors[0].Line != 3 || errors[1].Field != "middleware.2" {
+		t.Errorf("expected errors on fields 'middleware.1' and 'middleware.2'; got %v instead", errors)
+		return
+	}
+	if _, err := ReadConfig(filepath.Join(dir, "*.yaml")); err == nil {
+		t.Errorf("expected an error for middleware being defined by multiple files")
+		return
+	}
+	config, err = ReadConfig(filepath.Join(dir, "b.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m := config.MiddlewareOrder(); len(m) != 0 {
+		t.Errorf("expected no middleware; got %v instead", m)
+	}
+	if m := (&Config{}).MiddlewareOrder(); len(m) != len(DefaultMiddleware) {
+		t.Errorf("expected the default middleware; got %v instead", m)
+	}
+}
+
+func TestValidateCors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "cors:\n  allowed_origin_patterns:\n  - \"^https://(\"\n  max_age: -1\n",
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "a.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateCors()
+	if len(errors) != 2 || errors[0].Field != "cors.allowed_origin_patterns.0" || errors[0].Line != 3 || errors[1].Field != "cors.max_age" {
+		t.Errorf("expected errors on fields 'cors.allowed_origin_patterns.0' and 'cors.max_age'; got %v instead", errors)
+	}
+}
+
+func TestValidateListeners(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": `listeners:
+- name: users
+  protocol: http
+  address: ":8081"
+  tags: [users]
+- protocol: ftp
+  address: ":8082"
+- protocol: https
+  address: ":8081"
+  cert_file: server.crt
+- protocol: unix
+  address: /tmp/imposter.sock
+  http2: h3
+pattern_list:
+- rule_expression: ${true}
+  tags: [users]
+  response:
+    body: users
+- rule_expression: ${true}
+  response:
+    body: others
+`,
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "a.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors := config.ValidateListeners()
+	fields := make([]string, len(errors))
+	for i, e := range errors {
+		fields[i] = e.Field
+	}
+	if strings.Join(fields, ",") != "listeners.1.protocol,listeners.2.cert_file,listeners.2.address,listeners.3.http2" {
+		t.Errorf("unexpected errors %v", errors)
+	}
+	if n := len(config.ForListener(config.Listeners[0]).Defs); n != 1 {
+		t.Errorf("expected 1 rule to be served by the first listener; got %d instead", n)
+	}
+	if n := len(config.ForListener(config.Listeners[3]).Defs); n != 2 {
+		t.Errorf("expected all rules to be served by the last listener; got %d instead", n)
+	}
+	if n := len(config.Defs); n != 2 {
+		t.Errorf("expected the configuration not to be affected by listeners; got %d rules instead", n)
+	}
+}
+
+func TestValidateVirtualHosts(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": `virtual_hosts:
+- hosts: [users.local]
+  vars:
+    id: "/42"
+  pattern_list:
+  - rule_expression: ${eq(request_url_path(), var("id"))}
+    response:
+      status_code: "200"
+- host_patterns: ["("]
+`,
+		"b.yaml": `virtual_hosts:
+- hosts: [orders.local]
+  pattern_list:
+  - rule_expression: ${true}
+    tags: [orders]
+    response:
+      body: orders
+`,
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(config.VirtualHosts) != 3 {
+		t.Errorf("expected virtual hosts to be merged; got %d instead", len(config.VirtualHosts))
+		return
+	}
+	errors := config.ValidateVirtualHosts(functions.ParseExpression, nil)
+	fields := make([]string, len(errors))
+	for i, e := range errors {
+		fields[i] = fmt.Sprintf("%s@%d", e.Field, e.Line)
+	}
+	if strings.Join(fields, ",") != "response.status_code@8,host_patterns.0@9" {
+		t.Errorf("unexpected errors %v", errors)
+	}
+	if p := config.VirtualHosts[0].Defs[0].Source(); p.Line != 6 {
+		t.Errorf("expected the rule of the virtual host at line 6; got %v instead", p)
+	}
+	config.FilterTags([]string{"orders"})
+	if len(config.VirtualHosts[0].Defs) != 0 || len(config.VirtualHosts[2].Defs) != 1 {
+		t.Errorf("expected the rules of virtual hosts to be filtered by tags")
+	}
+}
+
+func TestGRPCResponse(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `pattern_list:
+- rule_expression: ${true}
+  response:
+    grpc:
+      body:
+        message: hello
+      trailers:
+        x-served-by: imposter
+- rule_expression: ${true}
+  response:
+    grpc:
+      status: 100
+- rule_expression: ${true}
+  response:
+    grpc:
+      body: {}
+      stream: [{}]
+`,
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if errors := config.ValidateStructure(); len(errors) > 0 {
+		t.Errorf("expected no structural errors; got %v instead", errors)
+		return
+	}
+	rsp, err := DecodeGRPCRsp(config.Defs[0].Response)
+	if err != nil || rsp == nil {
+		t.Errorf("expected a gRPC response; got %v instead", err)
+		return
+	}
+	if m, ok := rsp.Body.(map[string]interface{}); !ok || m["message"] != "hello" {
+		t.Errorf("expected body {message: hello}; got %v instead", rsp.Body)
+	}
+	if errors := config.Defs[0].Validate(functions.ParseExpression, nil); len(errors) > 0 {
+		t.Errorf("expected no errors; got %v instead", errors)
+	}
+	for _, def := range config.Defs[1:] {
+		errors := def.Validate(functions.ParseExpression, nil)
+		if len(errors) != 1 || errors[0].Field != "response.grpc" {
+			t.Errorf("expected a single error on field 'response.grpc'; got %v instead", errors)
+		}
+	}
+}
+
+func TestGRPCStatus(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.json": `{
+  "pattern_list": [
+    {"rule_expression": "${true}", "response": {"grpc": {"status": 5}}},
+    {"rule_expression": "${true}", "response": {"grpc": {"status": "NOT_FOUND"}}}
+  ]
+}`,
+		"invalid.json": `{
+  "pattern_list": [
+    {"rule_expression": "${true}", "response": {"grpc": {"status": true}}}
+  ]
+}`,
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if errors := config.ValidateStructure(); len(errors) > 0 {
+		t.Errorf("expected no structural errors; got %v instead", errors)
+		return
+	}
+	for _, def := range config.Defs {
+		if errors := def.Validate(functions.ParseExpression, nil); len(errors) > 0 {
+			t.Errorf("expected no errors; got %v instead", errors)
+			continue
+		}
+		rsp, err := DecodeGRPCRsp(def.Response)
+		if err != nil || rsp == nil {
+			t.Errorf("expected a gRPC response; got %v instead", err)
+			continue
+		}
+		if code, err := ParseGRPCStatus(rsp.Status); err != nil || code != 5 {
+			t.Errorf("expected status 5; got %d (%v) instead", code, err)
+		}
+	}
+	config, err = ReadConfig(filepath.Join(dir, "invalid.json"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if errors := config.ValidateStructure(); len(errors) != 1 || errors[0].Field != "response.grpc.status" {
+		t.Errorf("expected a single structural error on field 'response.grpc.status'; got %v instead", errors)
+	}
+}
+
+func TestWebSocketResponse(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `pattern_list:
+- rule_expression: ${true}
+  response:
+    websocket:
+      on_connect: [hello]
+      replies:
+      - match: ${eq(websocket_message(), "ping")}
+        send: [pong]
+      pushes:
+      - message: tick
+        interval: 1000
+      close:
+        after: 5000
+        code: 4000
+- rule_expression: ${true}
+  response:
+    websocket:
+      replies:
+      - match: ${websocket_message()}
+      close:
+        code: 1005
+`,
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if errors := config.ValidateStructure(); len(errors) > 0 {
+		t.Errorf("expected no structural errors; got %v instead", errors)
+		return
+	}
+	if errors := config.Defs[0].Validate(functions.ParseExpression, nil); len(errors) > 0 {
+		t.Errorf("expected no errors; got %v instead", errors)
+	}
+	errors := config.Defs[1].Validate(functions.ParseExpression, nil)
+	if len(errors) != 2 || errors[0].Field != "response.websocket.replies.0.match" || errors[1].Field != "response.websocket.close" {
+		t.Errorf("expected errors on fields 'response.websocket.replies.0.match' and 'response.websocket.close'; got %v instead", errors)
+	}
+}
+
+func TestSSEResponse(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `pattern_list:
+- rule_expression: ${true}
+  response:
+    sse:
+      events:
+      - event: tick
+        data: ${now()}
+        delay: 1000
+      repeat: -1
+- rule_expression: ${true}
+  response:
+    sse:
+      events:
+      - data: ${unknown()}
+      repeat: -2
+`,
+		"config.toml": `[[pattern_list]]
+rule_expression = "${true}"
+
+[[pattern_list.response.sse.events]]
+data = "tick"
+delay = -1
+retry = -1
+`,
+	})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if errors := config.ValidateStructure(); len(errors) != 1 || errors[0].Field != "response.sse.repeat" {
+		t.Errorf("expected a single structural error on field 'response.sse.repeat'; got %v instead", errors)
+		return
+	}
+	if errors := config.Defs[0].Validate(functions.ParseExpression, nil); len(errors) > 0 {
+		t.Errorf("expected no errors; got %v instead", errors)
+	}
+	errors := config.Defs[1].Validate(functions.ParseExpression, nil)
+	if len(errors) != 2 || errors[0].Field != "response.sse.repeat" || errors[1].Field != "response.sse.events.0.data" {
+		t.Errorf("expected errors on fields 'response.sse.repeat' and 'response.sse.events.0.data'; got %v instead", errors)
+	}
+	// bounds are checked by rules as well, whatever the format of the configuration
+	config, err = ReadConfig(filepath.Join(dir, "config.toml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	errors = config.Defs[0].Validate(functions.ParseExpression, nil)
+	if len(errors) != 2 || errors[0].Field != "response.sse.events.0.delay" || errors[1].Field != "response.sse.events.0.retry" {
+		t.Errorf("expected errors on fields 'response.sse.events.0.delay' and 'response.sse.events.0.retry'; got %v instead", errors)
+	}
+}
+
+func TestGRPCPaths(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{"config.yaml": "grpc:\n  proto_files: [protos/greeter.proto]\n  descriptor_sets: [/tmp/set.pb]\n"})
+	defer os.RemoveAll(dir)
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if f := config.GRPC.ProtoFiles[0]; f != filepath.Join(dir, "protos", "greeter.proto") {
+		t.Errorf("expected proto files to be resolved relative to the configuration file; got '%s' instead", f)
+	}
+	if f := config.GRPC.DescriptorSets[0]; f != "/tmp/set.pb" {
+		t.Errorf("expected absolute paths to be retained; got '%s' instead", f)
+	}
+}
+
+// diagnosticFields returns the fields diagnostics refer to, nil whether there are none.
+func diagnosticFields(diagnostics []*Diagnostic) []string {
+	var r []string
+	for _, d := range diagnostics {
+		r = append(r, d.Field)
+	}
+	return r
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		fields []string
+	}{
+		{"valid", "pattern_list:\n- rule_expression: ${true}\n  response:\n    body: ok\n", nil},
+		{"structural errors first", "pattern_list:\n- rule_expression: ${true}\n  latency: -1\n  response:\n    body: ok\n- rule_expression: ${1}\n  response:\n    body: ok\n", []string{"latency"}},
+		{"rules", "pattern_list:\n- rule_expression: ${1}\n  response:\n    body: ok\n", []string{"rule_expression"}},
+		{"fallback", "default_response:\n  body: none\nstrict: true\n", []string{"strict"}},
+		{"middleware", "middleware: [logging, unknown]\n", []string{"middleware.1"}},
+		{"gRPC services", "grpc:\n  proto_files: [missing.proto]\n", []string{"grpc"}},
+		{"virtual hosts", "virtual_hosts:\n- pattern_list:\n  - rule_expression: ${true}\n    response:\n      body: ok\n", []string{""}},
+	}
+	for _, test := range tests {
+		dir := writeConfigFiles(t, map[string]string{"config.yaml": test.config})
+		config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+		os.RemoveAll(dir)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if fields := diagnosticFields(config.Validate(functions.ParseExpression)); !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s: expected errors on fields %q; got %q instead", test.name, test.fields, fields)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package cfg
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CorsConfig represents the Cross-Origin Resource Sharing policy.
+// Requests are allowed whether their origin is listed by AllowedOrigins ("*" allowing any origin)
+// or it matches any of the regular expressions listed by AllowedOriginPatterns.
+// Preflight requests are answered by AllowedMethods and AllowedHeaders (the requested ones are allowed when not specified)
+// and they can be cached for MaxAge seconds, while ExposedHeaders are readable by clients of actual requests.
+type CorsConfig struct {
+	AllowedOrigins        []string `json:"allowed_origins" yaml:"allowed_origins"`
+	AllowedOriginPatterns []string `json:"allowed_origin_patterns" yaml:"allowed_origin_patterns"`
+	AllowedMethods        []string `json:"allowed_methods" yaml:"allowed_methods"`
+	AllowedHeaders        []string `json:"allowed_headers" yaml:"allowed_headers"`
+	ExposedHeaders        []string `json:"exposed_headers" yaml:"exposed_headers"`
+	MaxAge                int      `json:"max_age" yaml:"max_age"`
+	AllowCredentials      bool     `json:"allow_credentials" yaml:"allow_credentials"`
+}
+
+// DefaultCors is the policy applied by the -cors flag whether no policy is configured: any origin is allowed.
+var DefaultCors = &CorsConfig{AllowedOrigins: []string{"*"}}
+
+// ValidateCors checks the Cross-Origin Resource Sharing policy, if any.
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateCors() []*Diagnostic {
+	if c.Cors == nil {
+		return nil
+	}
+	var r []*Diagnostic
+	if len(c.Cors.AllowedOrigins) == 0 && len(c.Cors.AllowedOriginPatterns) == 0 {
+		r = append(r, c.sectionDiagnostic(sectionCors, fmt.Errorf("at least one of 'allowed_origins' and 'allowed_origin_patterns' is required"), "cors"))
+	}
+	for i, p := range c.Cors.AllowedOriginPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			r = append(r, c.sectionDiagnostic(sectionCors, err, "cors", "allowed_origin_patterns", i))
+		}
+	}
+	if c.Cors.MaxAge < 0 {
+		r = append(r, c.sectionDiagnostic(sectionCors, fmt.Errorf("max_age requires a value greater than or equal to zero"), "cors", "max_age"))
+	}
+	return r
+}
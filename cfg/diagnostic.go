@@ -0,0 +1,64 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic represents a validation error traced back to its originating configuration file.
+// Rule is the index of the offending rule within the pattern_list of its file (-1 whether the error
+// is not related to any rule), RuleName is its name (whether available) and Field is the dotted path of the offending field within the rule
+// (e.g. "response.status_code").
+type Diagnostic struct {
+	Position
+	Rule     int    `json:"rule"`
+	RuleName string `json:"rule_name,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (d *Diagnostic) String() string {
+	var b strings.Builder
+	if p := d.Position.String(); p != "" {
+		fmt.Fprintf(&b, "%s: ", p)
+	}
+	if d.RuleName != "" {
+		fmt.Fprintf(&b, "rule '%s' (#%d): ", d.RuleName, d.Rule)
+	} else if d.Rule >= 0 {
+		fmt.Fprintf(&b, "rule #%d: ", d.Rule)
+	}
+	if d.Field != "" {
+		fmt.Fprintf(&b, "%s: ", d.Field)
+	}
+	b.WriteString(d.Message)
+	return b.String()
+}
+
+// DiagnosticError is returned whether a configuration file cannot be decoded due to structural errors.
+type DiagnosticError struct {
+	Diagnostics []*Diagnostic
+}
+
+func (e *DiagnosticError) Error() string {
+	r := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		r[i] = d.String()
+	}
+	return strings.Join(r, "\n")
+}
+
+// diagnostic builds a Diagnostic for the rule field identified by path.
+func (def *MatchDef) diagnostic(err error, path ...interface{}) *Diagnostic {
+	p := def.src.position(def.path(path...)...)
+	d := newDiagnostic(p, def.index, err, path)
+	d.RuleName = def.Name
+	return d
+}
+
+func newDiagnostic(p Position, rule int, err error, path []interface{}) *Diagnostic {
+	field := make([]string, len(path))
+	for i, e := range path {
+		field[i] = fmt.Sprintf("%v", e)
+	}
+	return &Diagnostic{Position: p, Rule: rule, Field: strings.Join(field, "."), Message: err.Error()}
+}
@@ -0,0 +1,52 @@
+package cfg
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/naighes/imposter/functions"
+)
+
+// ValidateFallback checks the handling of unmatched requests trying to catch potential evaluation errors.
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateFallback(parse functions.ExpressionParser, vars map[string]interface{}) []*Diagnostic {
+	var keys []string
+	if c.DefaultResponse != nil {
+		keys = append(keys, "default_response")
+	}
+	if c.Upstream != "" {
+		keys = append(keys, "upstream")
+	}
+	if c.Strict {
+		keys = append(keys, "strict")
+	}
+	if len(keys) > 1 {
+		return []*Diagnostic{c.sectionDiagnostic(sectionFallback, fmt.Errorf("'%s' are mutually exclusive", strings.Join(keys, "', '")), keys[1])}
+	}
+	var r []*Diagnostic
+	if c.DefaultResponse != nil {
+		loc := func(err error, path ...interface{}) *Diagnostic {
+			return c.sectionDiagnostic(sectionFallback, err, path...)
+		}
+		r = append(r, validateResponse(loc, c.DefaultResponse, []interface{}{"default_response"}, parse, vars)...)
+	}
+	if c.Upstream != "" {
+		if _, err := ParseUpstream(c.Upstream); err != nil {
+			r = append(r, c.sectionDiagnostic(sectionFallback, err, "upstream"))
+		}
+	}
+	return r
+}
+
+// ParseUpstream parses the URL unmatched requests are proxied to.
+func ParseUpstream(upstream string) (*url.URL, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("expected an absolute HTTP(S) URL; got '%s' instead", upstream)
+	}
+	return u, nil
+}
@@ -0,0 +1,219 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v2"
+)
+
+// Format represents the syntax of a configuration file.
+type Format string
+
+// Supported configuration formats.
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+	FormatHCL  Format = "hcl"
+)
+
+var formatExtensions = map[string]Format{
+	".json": FormatJSON,
+	".yaml": FormatYAML,
+	".yml":  FormatYAML,
+	".toml": FormatTOML,
+	".hcl":  FormatHCL,
+}
+
+// ParseFormat converts a string into a Format.
+// An empty string is converted to an empty Format, meaning that the format is detected by file extension.
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(s))
+	switch f {
+	case "", FormatJSON, FormatYAML, FormatTOML, FormatHCL:
+		return f, nil
+	default:
+		return "", fmt.Errorf("'%s' is not a valid configuration format: select one from {'json', 'yaml', 'toml', 'hcl'}", s)
+	}
+}
+
+// detectFormat determines the format of a file by its extension.
+// YAML is assumed for unknown extensions, being a superset of JSON.
+func detectFormat(file string) Format {
+	if f, ok := formatExtensions[strings.ToLower(filepath.Ext(file))]; ok {
+		return f
+	}
+	return FormatYAML
+}
+
+// syntaxError wraps the errors raised by a parser for malformed content, as opposed to the errors raised
+// while decoding well-formed content (e.g. unknown keys or values of the wrong type).
+type syntaxError struct {
+	err error
+}
+
+func (e *syntaxError) Error() string {
+	return e.err.Error()
+}
+
+// parseConfig strictly decodes a configuration, so that unknown keys are rejected.
+func parseConfig(raw []byte, format Format) (*Config, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(raw)
+	case FormatTOML:
+		return parseTOML(raw)
+	case FormatHCL:
+		return parseHCL(raw)
+	default:
+		return parseYaml(raw)
+	}
+}
+
+func parseJSON(raw []byte) (*Config, error) {
+	var r *Config
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.DisallowUnknownFields()
+	err := d.Decode(&r)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			return nil, &syntaxError{fmt.Errorf("%v (%s)", err, offsetPosition(raw, e.Offset))}
+		case *json.UnmarshalTypeError:
+			return nil, fmt.Errorf("%v (%s)", err, offsetPosition(raw, e.Offset))
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, &syntaxError{err}
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// offsetPosition converts a byte offset into a line and column pair.
+func offsetPosition(raw []byte, offset int64) string {
+	if offset > int64(len(raw)) {
+		offset = int64(len(raw))
+	}
+	b := raw[:offset]
+	line := bytes.Count(b, []byte("\n")) + 1
+	column := len(b) - bytes.LastIndexByte(b, '\n') - 1
+	return fmt.Sprintf("line %d, column %d", line, column)
+}
+
+func parseYaml(raw []byte) (*Config, error) {
+	var r *Config
+	err := yaml.UnmarshalStrict(raw, &r)
+	if _, ok := err.(*yaml.TypeError); err != nil && !ok {
+		return nil, &syntaxError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseTOML(raw []byte) (*Config, error) {
+	m, err := decodeTOML(raw)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMap(m)
+}
+
+func decodeTOML(raw []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if _, err := toml.Decode(string(raw), &m); err != nil {
+		return nil, &syntaxError{err}
+	}
+	return m, nil
+}
+
+func parseHCL(raw []byte) (*Config, error) {
+	m, err := decodeHCL(raw)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMap(m)
+}
+
+func decodeHCL(raw []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := hcl.Unmarshal(raw, &m); err != nil {
+		return nil, &syntaxError{err}
+	}
+	return normalizeHCL(m).(map[string]interface{}), nil
+}
+
+// hclBlockLists are the keys expected to be lists of blocks.
+var hclBlockLists = map[string]bool{"pattern_list": true, "responses": true, "listeners": true, "virtual_hosts": true, "stream": true, "replies": true, "pushes": true, "events": true}
+
+// normalizeHCL unwraps single blocks, which are decoded as lists of objects, into objects:
+// just the keys within hclBlockLists are expected to be lists of blocks.
+func normalizeHCL(v interface{}) interface{} {
+	switch e := v.(type) {
+	case map[string]interface{}:
+		for k, a := range e {
+			if l, ok := a.([]map[string]interface{}); ok && !hclBlockLists[k] {
+				var m map[string]interface{}
+				if len(l) > 0 {
+					m = l[0]
+				}
+				for _, b := range l[1:] {
+					for bk, bv := range b {
+						m[bk] = bv
+					}
+				}
+				a = m
+			}
+			e[k] = normalizeHCL(a)
+		}
+		return e
+	case []map[string]interface{}:
+		r := make([]interface{}, len(e))
+		for i, m := range e {
+			r[i] = normalizeHCL(m)
+		}
+		return r
+	case []interface{}:
+		for i, a := range e {
+			e[i] = normalizeHCL(a)
+		}
+		return e
+	default:
+		return v
+	}
+}
+
+// decodeMap strictly decodes a generic representation of a configuration by relying on the JSON field names.
+func decodeMap(m map[string]interface{}) (*Config, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	var r Config
+	var md mapstructure.Metadata
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{TagName: "json", Result: &r, Metadata: &md})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(m); err != nil {
+		return nil, err
+	}
+	if len(md.Unused) > 0 {
+		sort.Strings(md.Unused)
+		return nil, fmt.Errorf("unknown keys: %s", strings.Join(md.Unused, ", "))
+	}
+	return &r, nil
+}
@@ -0,0 +1,249 @@
+package cfg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/mitchellh/mapstructure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// GRPCConfig declares the gRPC services to be mocked, either by .proto files (resolving imports
+// by ImportPaths) or by descriptor sets (e.g. generated by protoc --descriptor_set_out --include_imports).
+// Paths are relative to the configuration file.
+type GRPCConfig struct {
+	ProtoFiles     []string `json:"proto_files" yaml:"proto_files"`
+	ImportPaths    []string `json:"import_paths" yaml:"import_paths"`
+	DescriptorSets []string `json:"descriptor_sets" yaml:"descriptor_sets"`
+}
+
+// GRPCRsp represents the response to a gRPC call.
+// A unary call is answered by Body, while a server-streaming call is answered by the messages of Stream,
+// which are sent every Interval (in milliseconds); messages are defined by their JSON mapping.
+// Status is either a code name (e.g. NOT_FOUND) or its numeric value, along with an optional Message.
+// Headers and Trailers are sent as response metadata.
+//
+//	rsp := GRPCRsp{Status: "NOT_FOUND", Message: "no such user"}
+type GRPCRsp struct {
+	Status   interface{}       `mapstructure:"status"`
+	Message  string            `mapstructure:"message"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Trailers map[string]string `mapstructure:"trailers"`
+	Body     interface{}       `mapstructure:"body"`
+	Stream   []interface{}     `mapstructure:"stream"`
+	Interval time.Duration     `mapstructure:"interval"`
+}
+
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS",
+	"PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE",
+	"UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// ParseGRPCStatus converts a code name (e.g. NOT_FOUND) or a numeric value into a gRPC status code;
+// a nil value is converted to OK.
+func ParseGRPCStatus(v interface{}) (int, error) {
+	switch s := v.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		if s >= 0 && s < len(grpcCodes) {
+			return s, nil
+		}
+	case int64:
+		if s >= 0 && s < int64(len(grpcCodes)) {
+			return int(s), nil
+		}
+	case uint64:
+		if s < uint64(len(grpcCodes)) {
+			return int(s), nil
+		}
+	case float64:
+		// JSON numbers are decoded as float64
+		if s >= 0 && s < float64(len(grpcCodes)) && s == math.Trunc(s) {
+			return int(s), nil
+		}
+	case string:
+		for i, c := range grpcCodes {
+			if strings.EqualFold(s, c) {
+				return i, nil
+			}
+		}
+		if i, err := strconv.Atoi(s); err == nil {
+			return ParseGRPCStatus(i)
+		}
+	}
+	return 0, fmt.Errorf("'%v' is not a valid gRPC status: select a code from {'%s'} or its numeric value", v, strings.Join(grpcCodes, "', '"))
+}
+
+// DecodeGRPCRsp decodes the gRPC version of a Response object, which wraps a GRPCRsp by the grpc key.
+// Nil is returned whether the object is not a gRPC response.
+func DecodeGRPCRsp(o interface{}) (*GRPCRsp, error) {
+	var keys map[string]interface{}
+	if err := mapstructure.Decode(o, &keys); err != nil {
+		return nil, nil
+	}
+	g, ok := keys["grpc"]
+	if !ok {
+		return nil, nil
+	}
+	if len(keys) > 1 {
+		return nil, fmt.Errorf("'grpc' cannot be specified along with other keys")
+	}
+	var rsp GRPCRsp
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, WeaklyTypedInput: true, Result: &rsp})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(g); err != nil {
+		return nil, err
+	}
+	if rsp.Body != nil && rsp.Stream != nil {
+		return nil, fmt.Errorf("'body' and 'stream' are mutually exclusive")
+	}
+	if rsp.Interval < 0 {
+		return nil, fmt.Errorf("interval requires a value greater than or equal to zero")
+	}
+	if _, err := ParseGRPCStatus(rsp.Status); err != nil {
+		return nil, err
+	}
+	rsp.Body = jsonValue(rsp.Body)
+	for i, m := range rsp.Stream {
+		rsp.Stream[i] = jsonValue(m)
+	}
+	return &rsp, nil
+}
+
+// jsonValue converts the maps decoded from YAML, whose keys are not strings, so that they can be encoded to JSON.
+func jsonValue(v interface{}) interface{} {
+	switch e := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(e))
+		for k, a := range e {
+			m[fmt.Sprintf("%v", k)] = jsonValue(a)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(e))
+		for k, a := range e {
+			m[k] = jsonValue(a)
+		}
+		return m
+	case []interface{}:
+		r := make([]interface{}, len(e))
+		for i, a := range e {
+			r[i] = jsonValue(a)
+		}
+		return r
+	default:
+		return v
+	}
+}
+
+// ValidateGRPC checks the declaration of gRPC services, if any.
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateGRPC() []*Diagnostic {
+	if c.GRPC == nil {
+		return nil
+	}
+	if len(c.GRPC.ProtoFiles) == 0 && len(c.GRPC.DescriptorSets) == 0 {
+		return []*Diagnostic{c.sectionDiagnostic(sectionGRPC, fmt.Errorf("at least one of 'proto_files' and 'descriptor_sets' is required"), "grpc")}
+	}
+	if _, err := c.GRPC.LoadMethods(); err != nil {
+		return []*Diagnostic{c.sectionDiagnostic(sectionGRPC, fmt.Errorf("could not load gRPC services: %v", err), "grpc")}
+	}
+	return nil
+}
+
+// LoadMethods compiles the declared .proto files and loads the descriptor sets, returning the descriptors
+// of the methods of their services by path (e.g. /helloworld.Greeter/SayHello).
+func (c *GRPCConfig) LoadMethods() (map[string]protoreflect.MethodDescriptor, error) {
+	methods := make(map[string]protoreflect.MethodDescriptor)
+	for _, file := range c.DescriptorSets {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(b, &set); err != nil {
+			return nil, fmt.Errorf("could not decode descriptor set %s: %v", file, err)
+		}
+		files, err := protodesc.NewFiles(&set)
+		if err != nil {
+			return nil, fmt.Errorf("could not load descriptor set %s: %v", file, err)
+		}
+		files.RangeFiles(func(f protoreflect.FileDescriptor) bool {
+			addMethods(methods, f)
+			return true
+		})
+	}
+	if len(c.ProtoFiles) == 0 {
+		return methods, nil
+	}
+	importPaths := append([]string{}, c.ImportPaths...)
+	names := make([]string, len(c.ProtoFiles))
+	for i, file := range c.ProtoFiles {
+		name, ok := relativeToAny(file, importPaths)
+		if !ok {
+			// files out of any import path are resolved by their own directory
+			importPaths = append(importPaths, filepath.Dir(file))
+			name = filepath.Base(file)
+		}
+		names[i] = name
+	}
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	files, err := compiler.Compile(context.Background(), names...)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		addMethods(methods, f)
+	}
+	return methods, nil
+}
+
+func addMethods(methods map[string]protoreflect.MethodDescriptor, f protoreflect.FileDescriptor) {
+	for i := 0; i < f.Services().Len(); i++ {
+		service := f.Services().Get(i)
+		for j := 0; j < service.Methods().Len(); j++ {
+			method := service.Methods().Get(j)
+			methods[fmt.Sprintf("/%s/%s", service.FullName(), method.Name())] = method
+		}
+	}
+}
+
+// relativeToAny returns the path of file relative to the first of dirs containing it.
+func relativeToAny(file string, dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, file)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// resolvePaths makes the specified paths relative to the directory of file, unless they are absolute.
+func resolvePaths(file string, paths []string) []string {
+	r := make([]string, len(paths))
+	for i, p := range paths {
+		if filepath.IsAbs(p) {
+			r[i] = p
+		} else {
+			r[i] = filepath.Join(filepath.Dir(file), p)
+		}
+	}
+	return r
+}
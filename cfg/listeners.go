@@ -0,0 +1,116 @@
+package cfg
+
+import (
+	"fmt"
+	"net"
+)
+
+// Supported HTTP/2 modes.
+const (
+	// HTTP2Auto negotiates HTTP/2 with TLS clients.
+	HTTP2Auto = "auto"
+	// HTTP2Off disables HTTP/2.
+	HTTP2Off = "off"
+	// HTTP2H2C negotiates HTTP/2 with TLS clients and accepts cleartext HTTP/2 (h2c) as well, either by
+	// prior knowledge or by upgrade.
+	HTTP2H2C = "h2c"
+	// HTTP2Only works like HTTP2H2C, while HTTP/1 requests are answered by a 505 status code.
+	HTTP2Only = "h2"
+)
+
+// ParseHTTP2Mode checks the specified HTTP/2 mode; an empty string is converted to the auto mode.
+func ParseHTTP2Mode(s string) (string, error) {
+	switch s {
+	case "":
+		return HTTP2Auto, nil
+	case HTTP2Auto, HTTP2Off, HTTP2H2C, HTTP2Only:
+		return s, nil
+	default:
+		return "", fmt.Errorf("'%s' is not a valid HTTP/2 mode: select one from {'auto', 'off', 'h2c', 'h2'}", s)
+	}
+}
+
+// Supported listener protocols.
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+	ProtocolUnix  = "unix"
+)
+
+// ListenerConfig represents an endpoint requests are accepted from.
+// Address is a TCP address (e.g. :8080) for the http and https protocols, or the path of the socket
+// for the unix protocol. HTTPS listeners are secured by CertFile and KeyFile, when specified, or by the
+// certificates of the start command otherwise.
+// Just the rules labeled by at least one of Tags are served, whether specified.
+// HTTP2 overrides the HTTP/2 mode of the start command.
+type ListenerConfig struct {
+	Name     string   `json:"name" yaml:"name"`
+	Protocol string   `json:"protocol" yaml:"protocol"`
+	Address  string   `json:"address" yaml:"address"`
+	CertFile string   `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string   `json:"key_file" yaml:"key_file"`
+	Tags     []string `json:"tags" yaml:"tags"`
+	HTTP2    string   `json:"http2" yaml:"http2"`
+}
+
+// Label returns a description of the listener for logs and error messages.
+func (l *ListenerConfig) Label() string {
+	if l.Name != "" {
+		return fmt.Sprintf("listener '%s'", l.Name)
+	}
+	return fmt.Sprintf("%s listener on %s", l.Protocol, l.Address)
+}
+
+// ForListener returns a copy of the configuration holding just the rules served by the specified listener.
+func (c *Config) ForListener(l *ListenerConfig) *Config {
+	r := *c
+	r.FilterTags(l.Tags)
+	return &r
+}
+
+// ValidateListeners checks the listeners, if any.
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateListeners() []*Diagnostic {
+	var r []*Diagnostic
+	names := make(map[string]bool)
+	addresses := make(map[string]bool)
+	for i, l := range c.Listeners {
+		if l == nil {
+			r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("a listener is expected"), "listeners", i))
+			continue
+		}
+		switch l.Protocol {
+		case ProtocolHTTP, ProtocolUnix:
+			if l.CertFile != "" || l.KeyFile != "" {
+				r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("certificates are supported by the https protocol only"), "listeners", i, "cert_file"))
+			}
+		case ProtocolHTTPS:
+			if (l.CertFile == "") != (l.KeyFile == "") {
+				r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("'cert_file' and 'key_file' are required together"), "listeners", i, "cert_file"))
+			}
+		default:
+			r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("'%s' is not a valid protocol: select one from {'http', 'https', 'unix'}", l.Protocol), "listeners", i, "protocol"))
+		}
+		if l.Address == "" {
+			r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("missing required key 'address'"), "listeners", i))
+		} else if l.Protocol != ProtocolUnix {
+			if _, _, err := net.SplitHostPort(l.Address); err != nil {
+				r = append(r, c.sectionDiagnostic(sectionListeners, err, "listeners", i, "address"))
+			}
+		}
+		if _, err := ParseHTTP2Mode(l.HTTP2); err != nil {
+			r = append(r, c.sectionDiagnostic(sectionListeners, err, "listeners", i, "http2"))
+		}
+		if addresses[l.Address] {
+			r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("address '%s' is used by more than one listener", l.Address), "listeners", i, "address"))
+		}
+		addresses[l.Address] = true
+		if l.Name != "" {
+			if names[l.Name] {
+				r = append(r, c.sectionDiagnostic(sectionListeners, fmt.Errorf("listener name is not unique"), "listeners", i, "name"))
+			}
+			names[l.Name] = true
+		}
+	}
+	return r
+}
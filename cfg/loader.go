@@ -0,0 +1,267 @@
+package cfg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// loader merges a set of configuration files into a single Config.
+// Rules are appended in loading order: the rules of any included file come before
+// the ones of the including file, so that a trailing catch-all rule keeps working.
+type loader struct {
+	config *Config
+	loaded map[string]bool
+}
+
+func newLoader() *loader {
+	return &loader{
+		config: &Config{varPositions: make(map[string]Position)},
+		loaded: make(map[string]bool),
+	}
+}
+
+// resolveFiles expands a path into the list of configuration files it refers to.
+// A directory is expanded to the configuration files it directly contains, while
+// a glob pattern is expanded to its matches: in both cases files are sorted lexically.
+func resolveFiles(path string) ([]string, error) {
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %v", path, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no configuration file matches '%s'", path)
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var r []string
+	for _, e := range entries {
+		if !e.IsDir() && formatExtensions[strings.ToLower(filepath.Ext(e.Name()))] != "" {
+			r = append(r, filepath.Join(path, e.Name()))
+		}
+	}
+	if len(r) == 0 {
+		return nil, fmt.Errorf("no configuration file found in directory '%s'", path)
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+// load reads a configuration file and merges it; an empty format means detection by extension.
+func (l *loader) load(file string, format Format, stack []string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	for _, f := range stack {
+		if f == abs {
+			return fmt.Errorf("%s: include cycle detected", file)
+		}
+	}
+	if l.loaded[abs] {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = detectFormat(file)
+	}
+	return l.loadRaw(file, format, raw, stack)
+}
+
+func (l *loader) loadRaw(file string, format Format, raw []byte, stack []string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	l.loaded[abs] = true
+	if raw, err = ExpandEnv(raw); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	src := newSource(file, format, raw)
+	config, err := parseConfig(raw, format)
+	if err != nil {
+		// decoding errors are better described by the schema, which knows their position
+		if _, ok := err.(*syntaxError); !ok {
+			if d := validateSource(src); len(d) > 0 {
+				return &DiagnosticError{Diagnostics: d}
+			}
+		}
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if config == nil {
+		return nil
+	}
+	l.config.sources = append(l.config.sources, src)
+	for index, include := range config.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(file), include)
+		}
+		files, err := resolveFiles(include)
+		if err != nil {
+			return fmt.Errorf("%s: could not resolve include: %v", src.position("include", index), err)
+		}
+		for _, f := range files {
+			if err := l.load(f, "", append(stack, abs)); err != nil {
+				return err
+			}
+		}
+	}
+	for index, def := range config.Defs {
+		if def == nil {
+			continue
+		}
+		def.src = src
+		def.index = index
+		l.config.Defs = append(l.config.Defs, def)
+	}
+	for index, vh := range config.VirtualHosts {
+		if vh == nil {
+			continue
+		}
+		vh.src = src
+		vh.index = index
+		for i, def := range vh.Defs {
+			if def == nil {
+				continue
+			}
+			def.src = src
+			def.index = i
+			def.scope = []interface{}{"virtual_hosts", index}
+		}
+		l.config.VirtualHosts = append(l.config.VirtualHosts, vh)
+	}
+	for _, section := range sections {
+		if err := l.mergeSection(section, config, src); err != nil {
+			return err
+		}
+	}
+	return l.mergeVars(config.Vars, src)
+}
+
+// section is a part of the configuration which can be defined by a single file.
+type section struct {
+	name        string
+	description string
+	defined     func(config *Config) bool
+	merge       func(dst *Config, config *Config, src *source)
+}
+
+// Sections are identified by name within Config.sections, which keeps track of the files defining them.
+const (
+	sectionFallback   = "fallback"
+	sectionMiddleware = "middleware"
+	sectionCors       = "cors"
+	sectionListeners  = "listeners"
+	sectionGRPC       = "grpc"
+)
+
+var sections = []*section{
+	{
+		name:        sectionFallback,
+		description: "the handling of unmatched requests is",
+		defined: func(config *Config) bool {
+			return config.DefaultResponse != nil || config.Upstream != "" || config.Strict
+		},
+		merge: func(dst *Config, config *Config, src *source) {
+			dst.DefaultResponse = config.DefaultResponse
+			dst.Upstream = config.Upstream
+			dst.Strict = config.Strict
+		},
+	},
+	{
+		name:        sectionMiddleware,
+		description: "middleware are",
+		defined:     func(config *Config) bool { return config.Middleware != nil },
+		merge:       func(dst *Config, config *Config, src *source) { dst.Middleware = config.Middleware },
+	},
+	{
+		name:        sectionCors,
+		description: "the CORS policy is",
+		defined:     func(config *Config) bool { return config.Cors != nil },
+		merge:       func(dst *Config, config *Config, src *source) { dst.Cors = config.Cors },
+	},
+	{
+		name:        sectionListeners,
+		description: "listeners are",
+		defined:     func(config *Config) bool { return config.Listeners != nil },
+		merge:       func(dst *Config, config *Config, src *source) { dst.Listeners = config.Listeners },
+	},
+	{
+		name:        sectionGRPC,
+		description: "gRPC services are",
+		defined:     func(config *Config) bool { return config.GRPC != nil },
+		merge: func(dst *Config, config *Config, src *source) {
+			dst.GRPC = &GRPCConfig{
+				ProtoFiles:     resolvePaths(src.file, config.GRPC.ProtoFiles),
+				ImportPaths:    resolvePaths(src.file, config.GRPC.ImportPaths),
+				DescriptorSets: resolvePaths(src.file, config.GRPC.DescriptorSets),
+			}
+		},
+	},
+}
+
+// mergeSection merges the specified section, unless it is already defined by another file.
+func (l *loader) mergeSection(s *section, config *Config, src *source) error {
+	if !s.defined(config) {
+		return nil
+	}
+	if prev, ok := l.config.sections[s.name]; ok {
+		return fmt.Errorf("%s: %s already defined by %s", src.file, s.description, prev.file)
+	}
+	s.merge(l.config, config, src)
+	if l.config.sections == nil {
+		l.config.sections = make(map[string]*source)
+	}
+	l.config.sections[s.name] = src
+	return nil
+}
+
+// sectionDiagnostic builds a Diagnostic for the field identified by path, within the file defining the specified section.
+func (c *Config) sectionDiagnostic(name string, err error, path ...interface{}) *Diagnostic {
+	return newDiagnostic(c.sections[name].position(path...), -1, err, path)
+}
+
+func (l *loader) mergeVars(vars map[string]interface{}, src *source) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	if l.config.Vars == nil {
+		l.config.Vars = make(map[string]interface{})
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := src.position("vars", k)
+		if v, ok := l.config.Vars[k]; ok && !reflect.DeepEqual(v, vars[k]) {
+			return fmt.Errorf("%s: variable '%s' conflicts with the one defined at %s", p, k, l.config.varPositions[k])
+		}
+		l.config.Vars[k] = vars[k]
+		if _, ok := l.config.varPositions[k]; !ok {
+			l.config.varPositions[k] = p
+		}
+	}
+	return nil
+}
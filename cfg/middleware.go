@@ -0,0 +1,48 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported middleware, wrapping the router in the order they are listed by the Middleware field of Config.
+const (
+	// MiddlewareLogging logs every request along with its response.
+	MiddlewareLogging = "logging"
+	// MiddlewareCors provides support for Cross-Origin Resource Sharing.
+	MiddlewareCors = "cors"
+	// MiddlewareRecording answers requests by the recorded responses.
+	MiddlewareRecording = "recording"
+)
+
+// Middleware lists the names of all supported middleware.
+var Middleware = []string{MiddlewareLogging, MiddlewareCors, MiddlewareRecording}
+
+// DefaultMiddleware is the order middleware are applied in whether it is not configured.
+var DefaultMiddleware = []string{MiddlewareLogging, MiddlewareCors, MiddlewareRecording}
+
+// MiddlewareOrder returns the names of the middleware wrapping the router, from the outermost one.
+func (c *Config) MiddlewareOrder() []string {
+	if c.Middleware == nil {
+		return DefaultMiddleware
+	}
+	return c.Middleware
+}
+
+// ValidateMiddleware checks that middleware are supported and listed once.
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateMiddleware() []*Diagnostic {
+	var r []*Diagnostic
+	seen := make(map[string]bool)
+	for i, name := range c.Middleware {
+		if !containsString(Middleware, name) {
+			r = append(r, c.sectionDiagnostic(sectionMiddleware, fmt.Errorf("'%s' is not a valid middleware: select one from {'%s'}", name, strings.Join(Middleware, "', '")), "middleware", i))
+			continue
+		}
+		if seen[name] {
+			r = append(r, c.sectionDiagnostic(sectionMiddleware, fmt.Errorf("middleware '%s' is listed more than once", name), "middleware", i))
+		}
+		seen[name] = true
+	}
+	return r
+}
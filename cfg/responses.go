@@ -0,0 +1,70 @@
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/naighes/imposter/functions"
+)
+
+// ResponseMode determines how a response is picked from the Responses of a rule at every match.
+type ResponseMode string
+
+// Supported response modes.
+const (
+	// ResponseModeSequence advances through the responses at every match: once the last one is returned,
+	// the rule stops matching unless StickOnLast is set.
+	ResponseModeSequence ResponseMode = "sequence"
+	// ResponseModeCycle advances through the responses at every match, starting over after the last one.
+	ResponseModeCycle ResponseMode = "cycle"
+	// ResponseModeRandom picks a response at random.
+	ResponseModeRandom ResponseMode = "random"
+	// ResponseModeWeighted picks a response at random, proportionally to its Weight.
+	ResponseModeWeighted ResponseMode = "weighted"
+)
+
+// WeightedRsp wraps a Response object within a list of Responses.
+// Weight is taken into account by the weighted mode only.
+type WeightedRsp struct {
+	Response interface{} `json:"response" yaml:"response"`
+	Weight   int         `json:"weight" yaml:"weight"`
+}
+
+// ParseResponseMode converts a string into a ResponseMode; an empty string is converted to the sequence mode.
+func ParseResponseMode(s ResponseMode) (ResponseMode, error) {
+	switch s {
+	case "":
+		return ResponseModeSequence, nil
+	case ResponseModeSequence, ResponseModeCycle, ResponseModeRandom, ResponseModeWeighted:
+		return s, nil
+	default:
+		return "", fmt.Errorf("'%s' is not a valid response mode: select one from {'sequence', 'cycle', 'random', 'weighted'}", s)
+	}
+}
+
+func (def *MatchDef) validateResponses(parse functions.ExpressionParser, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
+	mode, err := ParseResponseMode(def.ResponseMode)
+	if err != nil {
+		r = append(r, def.diagnostic(err, "response_mode"))
+	}
+	if len(def.Responses) == 0 {
+		return append(r, def.diagnostic(fmt.Errorf("at least one response is required"), "responses"))
+	}
+	total := 0
+	for i, e := range def.Responses {
+		path := []interface{}{"responses", i}
+		if e == nil || e.Response == nil {
+			r = append(r, def.diagnostic(fmt.Errorf("missing required key 'response'"), path...))
+			continue
+		}
+		if e.Weight < 0 {
+			r = append(r, def.diagnostic(fmt.Errorf("weight requires a value greater than or equal to zero"), field(path, "weight")...))
+		}
+		total += e.Weight
+		r = append(r, validateResponse(def.diagnostic, e.Response, field(path, "response"), parse, vars)...)
+	}
+	if mode == ResponseModeWeighted && total <= 0 {
+		r = append(r, def.diagnostic(fmt.Errorf("the weighted mode requires at least one response with a positive weight"), "responses"))
+	}
+	return r
+}
@@ -0,0 +1,748 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ExpressionPattern matches any string wrapped into the evaluation block marker (${…}).
+const ExpressionPattern = `^\$\{[\s\S]*\}$`
+
+// jsonSchema is the subset of JSON Schema (draft 2020-12) used to describe the configuration format.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func expressionSchema(description string) *jsonSchema {
+	return &jsonSchema{Type: "string", Pattern: ExpressionPattern, Description: description}
+}
+
+var configSchema = &jsonSchema{
+	Schema:               "https://json-schema.org/draft/2020-12/schema",
+	ID:                   "https://github.com/naighes/imposter/config.schema.json",
+	Title:                "imPOSTer configuration",
+	Type:                 "object",
+	AdditionalProperties: false,
+	Properties: map[string]*jsonSchema{
+		"pattern_list": {
+			Type:        "array",
+			Description: "The rules incoming HTTP requests are matched against, in order.",
+			Items:       &jsonSchema{Ref: "#/$defs/match_def"},
+		},
+		"vars": {
+			Type:        "object",
+			Description: "Input variables, readable by the var built-in function.",
+		},
+		"default_response": {
+			Ref:         "#/$defs/response",
+			Description: "How requests matching no rule are handled.",
+		},
+		"upstream": {
+			Type:        "string",
+			Description: "The URL requests matching no rule are proxied to.",
+		},
+		"strict": {
+			Type:        "boolean",
+			Description: "Whether requests matching no rule are answered by a 501 status code along with their details.",
+		},
+		"middleware": {
+			Type:        "array",
+			Description: "The middleware wrapping the router, from the outermost one (logging, cors and recording when not specified).",
+			Items:       &jsonSchema{Type: "string", Enum: Middleware},
+		},
+		"cors": {
+			Type:                 "object",
+			Description:          "The Cross-Origin Resource Sharing policy, applied by the cors middleware.",
+			AdditionalProperties: false,
+			Properties: map[string]*jsonSchema{
+				"allowed_origins": {
+					Type:        "array",
+					Description: "The allowed origins (e.g. https://example.com); '*' allows any origin.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"allowed_origin_patterns": {
+					Type:        "array",
+					Description: "Regular expressions matching the allowed origins.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"allowed_methods": {
+					Type:        "array",
+					Description: "The methods allowed by preflight requests; the requested method is allowed when not specified.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"allowed_headers": {
+					Type:        "array",
+					Description: "The headers allowed by preflight requests; the requested headers are allowed when not specified.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"exposed_headers": {
+					Type:        "array",
+					Description: "The response headers readable by clients.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"max_age": {
+					Type:        "integer",
+					Minimum:     intPtr(0),
+					Description: "The number of seconds preflight responses can be cached for.",
+				},
+				"allow_credentials": {
+					Type:        "boolean",
+					Description: "Whether requests can include credentials (e.g. cookies).",
+				},
+			},
+		},
+		"listeners": {
+			Type:        "array",
+			Description: "The endpoints requests are accepted from, in place of the port of the start command.",
+			Items: &jsonSchema{
+				Type:                 "object",
+				AdditionalProperties: false,
+				Required:             []string{"protocol", "address"},
+				Properties: map[string]*jsonSchema{
+					"name": {
+						Type:        "string",
+						Description: "The name the listener is identified by in logs and error messages; it must be unique.",
+					},
+					"protocol": {
+						Type:        "string",
+						Enum:        []string{ProtocolHTTP, ProtocolHTTPS, ProtocolUnix},
+						Description: "The protocol requests are accepted by.",
+					},
+					"address": {
+						Type:        "string",
+						Description: "The TCP address (e.g. :8080) for the http and https protocols, or the path of the socket for the unix protocol.",
+					},
+					"cert_file": {
+						Type:        "string",
+						Description: "The X.509 certificate securing an https listener (the certificates of the start command when not specified).",
+					},
+					"key_file": {
+						Type:        "string",
+						Description: "The private key corresponding to cert_file.",
+					},
+					"tags": {
+						Type:        "array",
+						Description: "Just the rules labeled by at least one of these tags are served (all rules when not specified).",
+						Items:       &jsonSchema{Type: "string"},
+					},
+					"http2": {
+						Type:        "string",
+						Enum:        []string{HTTP2Auto, HTTP2Off, HTTP2H2C, HTTP2Only},
+						Description: "The HTTP/2 mode, overriding the one of the start command.",
+					},
+				},
+			},
+		},
+		"virtual_hosts": {
+			Type:        "array",
+			Description: "Groups of rules serving the requests addressed to specific hosts, in place of the top-level ones.",
+			Items: &jsonSchema{
+				Type:                 "object",
+				AdditionalProperties: false,
+				Properties: map[string]*jsonSchema{
+					"hosts": {
+						Type:        "array",
+						Description: "The host names requests are addressed to (e.g. users.local); '*.example.com' matches any subdomain of example.com.",
+						Items:       &jsonSchema{Type: "string"},
+					},
+					"host_patterns": {
+						Type:        "array",
+						Description: "Regular expressions matching the host names requests are addressed to.",
+						Items:       &jsonSchema{Type: "string"},
+					},
+					"pattern_list": {
+						Type:        "array",
+						Description: "The rules requests addressed to the virtual host are matched against, in order.",
+						Items:       &jsonSchema{Ref: "#/$defs/match_def"},
+					},
+					"vars": {
+						Type:        "object",
+						Description: "Input variables overriding the top-level ones.",
+					},
+					"default_response": {
+						Ref:         "#/$defs/response",
+						Description: "How requests matching no rule of the virtual host are handled (as top-level unmatched requests when not specified).",
+					},
+				},
+			},
+		},
+		"grpc": {
+			Type:                 "object",
+			Description:          "The gRPC services whose calls are decoded into JSON, so that they can be matched by rules.",
+			AdditionalProperties: false,
+			Properties: map[string]*jsonSchema{
+				"proto_files": {
+					Type:        "array",
+					Description: "The .proto files declaring the services, relative to the configuration file.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"import_paths": {
+					Type:        "array",
+					Description: "The directories imports of proto_files are resolved by (the directory of the configuration file when not specified).",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"descriptor_sets": {
+					Type:        "array",
+					Description: "Binary FileDescriptorSet files (e.g. generated by protoc --descriptor_set_out --include_imports).",
+					Items:       &jsonSchema{Type: "string"},
+				},
+			},
+		},
+		"include": {
+			Type:        "array",
+			Description: "Paths (or glob patterns) of further configuration files, relative to the including one.",
+			Items:       &jsonSchema{Type: "string"},
+		},
+	},
+	Defs: map[string]*jsonSchema{
+		"match_def": {
+			Type:                 "object",
+			Description:          "A single rule expression.",
+			AdditionalProperties: false,
+			Required:             []string{"rule_expression"},
+			Properties: map[string]*jsonSchema{
+				"name": {
+					Type:        "string",
+					Description: "The name the rule is identified by in logs and error messages; it must be unique.",
+				},
+				"priority": {
+					Type:        "integer",
+					Description: "Rules with a higher priority are tested first (0 when not specified); rules with the same priority are tested in order.",
+				},
+				"tags": {
+					Type:        "array",
+					Description: "Labels allowing to load a subset of rules by the -tags flag.",
+					Items:       &jsonSchema{Type: "string"},
+				},
+				"rule_expression": expressionSchema("A boolean expression every incoming HTTP request is matched against."),
+				"latency": {
+					Type:        "integer",
+					Minimum:     intPtr(0),
+					Description: "The delay, in milliseconds, before the response is returned.",
+				},
+				"response": {Ref: "#/$defs/response"},
+				"responses": {
+					Type:        "array",
+					Description: "A list of responses picked according to response_mode at every match (mutually exclusive with response).",
+					Items:       &jsonSchema{Ref: "#/$defs/weighted_response"},
+				},
+				"response_mode": {
+					Type:        "string",
+					Enum:        []string{"sequence", "cycle", "random", "weighted"},
+					Description: "How responses are picked: sequence (default), cycle, random or weighted.",
+				},
+				"stick_on_last": {
+					Type:        "boolean",
+					Description: "Whether the last response keeps being returned once a sequence is exhausted, rather than the rule not matching anymore.",
+				},
+			},
+		},
+		"response": {
+			Description: "How a matching request is handled: either a computed response, a structured one, a scripted one, a gRPC one, a WebSocket one or a Server-Sent Events one.",
+			OneOf: []*jsonSchema{
+				{Ref: "#/$defs/computed_response"},
+				{Ref: "#/$defs/match_rsp"},
+				{Ref: "#/$defs/script_rsp"},
+				{Ref: "#/$defs/grpc_rsp"},
+				{Ref: "#/$defs/websocket_rsp"},
+				{Ref: "#/$defs/sse_rsp"},
+			},
+		},
+		"weighted_response": {
+			Type:                 "object",
+			Description:          "A response within a list of responses.",
+			AdditionalProperties: false,
+			Required:             []string{"response"},
+			Properties: map[string]*jsonSchema{
+				"response": {Ref: "#/$defs/response"},
+				"weight": {
+					Type:        "integer",
+					Minimum:     intPtr(0),
+					Description: "The relative probability of the response being picked by the weighted mode.",
+				},
+			},
+		},
+		"script_rsp": {
+			Type:                 "object",
+			Description:          "A response computed by a JavaScript script.",
+			AdditionalProperties: false,
+			Properties: map[string]*jsonSchema{
+				"script": {
+					Type:        "string",
+					Description: "The body of a function receiving request, vars and state and returning {status, headers, body}.",
+				},
+				"script_file": {
+					Type:        "string",
+					Description: "The path of a file containing the script (mutually exclusive with script).",
+				},
+				"timeout": {
+					Type:        "integer",
+					Minimum:     intPtr(0),
+					Description: "The maximum execution time, in milliseconds (1000 when not specified).",
+				},
+			},
+		},
+		"grpc_rsp": {
+			Type:                 "object",
+			Description:          "The response to a gRPC call.",
+			AdditionalProperties: false,
+			Required:             []string{"grpc"},
+			Properties: map[string]*jsonSchema{
+				"grpc": {
+					Type:                 "object",
+					Description:          "The status, metadata and messages answering the call.",
+					AdditionalProperties: false,
+					Properties: map[string]*jsonSchema{
+						"status": {
+							Description: "The status code name (e.g. NOT_FOUND) or its numeric value (OK when not specified).",
+							OneOf: []*jsonSchema{
+								{Type: "integer", Minimum: intPtr(0)},
+								{Type: "string", Enum: grpcCodes},
+							},
+						},
+						"message": {
+							Type:        "string",
+							Description: "The status message.",
+						},
+						"headers": {
+							Type:                 "object",
+							Description:          "The metadata sent before messages.",
+							AdditionalProperties: &jsonSchema{Type: "string"},
+						},
+						"trailers": {
+							Type:                 "object",
+							Description:          "The metadata sent along with the status.",
+							AdditionalProperties: &jsonSchema{Type: "string"},
+						},
+						"body": {
+							Type:        "object",
+							Description: "The JSON mapping of the message answering a unary call.",
+						},
+						"stream": {
+							Type:        "array",
+							Description: "The JSON mapping of the messages answering a server-streaming call (mutually exclusive with body).",
+							Items:       &jsonSchema{Type: "object"},
+						},
+						"interval": {
+							Type:        "integer",
+							Minimum:     intPtr(0),
+							Description: "The delay, in milliseconds, between streamed messages.",
+						},
+					},
+				},
+			},
+		},
+		"websocket_rsp": {
+			Type:                 "object",
+			Description:          "Upgrades matching requests to WebSocket connections running a script of message exchanges.",
+			AdditionalProperties: false,
+			Required:             []string{"websocket"},
+			Properties: map[string]*jsonSchema{
+				"websocket": {
+					Type:                 "object",
+					Description:          "The messages exchanged over the connection; each message can be an expression.",
+					AdditionalProperties: false,
+					Properties: map[string]*jsonSchema{
+						"on_connect": {
+							Type:        "array",
+							Description: "The messages sent once the connection is established.",
+							Items:       &jsonSchema{Type: "string"},
+						},
+						"replies": {
+							Type:        "array",
+							Description: "Every incoming message is answered by the first reply whose match expression holds true.",
+							Items: &jsonSchema{
+								Type:                 "object",
+								AdditionalProperties: false,
+								Required:             []string{"match"},
+								Properties: map[string]*jsonSchema{
+									"match": expressionSchema("A boolean expression the incoming message, returned by websocket_message(), is matched against."),
+									"send": {
+										Type:        "array",
+										Description: "The messages answering the incoming one.",
+										Items:       &jsonSchema{Type: "string"},
+									},
+									"close": {Ref: "#/$defs/websocket_close"},
+								},
+							},
+						},
+						"pushes": {
+							Type:        "array",
+							Description: "Messages sent periodically.",
+							Items: &jsonSchema{
+								Type:                 "object",
+								AdditionalProperties: false,
+								Required:             []string{"message", "interval"},
+								Properties: map[string]*jsonSchema{
+									"message": {
+										Type:        "string",
+										Description: "The message to be sent.",
+									},
+									"interval": {
+										Type:        "integer",
+										Minimum:     intPtr(1),
+										Description: "The delay, in milliseconds, between messages.",
+									},
+									"count": {
+										Type:        "integer",
+										Minimum:     intPtr(0),
+										Description: "The number of messages to be sent (unlimited when not specified).",
+									},
+								},
+							},
+						},
+						"close": {Ref: "#/$defs/websocket_close"},
+					},
+				},
+			},
+		},
+		"websocket_close": {
+			Type:                 "object",
+			Description:          "Closes the connection.",
+			AdditionalProperties: false,
+			Properties: map[string]*jsonSchema{
+				"after": {
+					Type:        "integer",
+					Minimum:     intPtr(0),
+					Description: "The delay, in milliseconds, before closing the connection.",
+				},
+				"code": {
+					Type:        "integer",
+					Description: "The close code (1000 when not specified).",
+				},
+				"reason": {
+					Type:        "string",
+					Description: "The close reason.",
+				},
+			},
+		},
+		"sse_rsp": {
+			Type:                 "object",
+			Description:          "Streams events to the client as Server-Sent Events.",
+			AdditionalProperties: false,
+			Required:             []string{"sse"},
+			Properties: map[string]*jsonSchema{
+				"sse": {
+					Type:                 "object",
+					Description:          "The events streamed to the client, before keeping the connection open until it disconnects.",
+					AdditionalProperties: false,
+					Properties: map[string]*jsonSchema{
+						"events": {
+							Type:        "array",
+							Description: "The events to be streamed, in order.",
+							Items: &jsonSchema{
+								Type:                 "object",
+								AdditionalProperties: false,
+								Properties: map[string]*jsonSchema{
+									"id": {
+										Type:        "string",
+										Description: "The event ID; it can be an expression as well.",
+									},
+									"event": {
+										Type:        "string",
+										Description: "The event type; it can be an expression as well.",
+									},
+									"data": {
+										Type:        "string",
+										Description: "The event payload; it can be an expression as well, whose non-string values are encoded as JSON.",
+									},
+									"retry": {
+										Type:        "integer",
+										Minimum:     intPtr(0),
+										Description: "The reconnection time, in milliseconds, suggested to the client.",
+									},
+									"delay": {
+										Type:        "integer",
+										Minimum:     intPtr(0),
+										Description: "The delay, in milliseconds, before the event is sent.",
+									},
+								},
+							},
+						},
+						"repeat": {
+							Type:        "integer",
+							Minimum:     intPtr(-1),
+							Description: "The number of further times events are streamed (endlessly when -1).",
+						},
+					},
+				},
+			},
+		},
+		"computed_response": expressionSchema("An expression returning an HTTPRsp (e.g. link, redirect, …)."),
+		"match_rsp": {
+			Type:                 "object",
+			Description:          "The fully structured version of a response object.",
+			AdditionalProperties: false,
+			Properties: map[string]*jsonSchema{
+				"body": {
+					Type:        "string",
+					Description: "The payload to be returned; it can be an expression as well.",
+				},
+				"headers": {
+					Type:                 "object",
+					Description:          "The HTTP headers to be returned; each entry can be an expression.",
+					AdditionalProperties: &jsonSchema{Type: "string"},
+				},
+				"status_code": expressionSchema("An expression evaluating to the HTTP status code to be returned (200 when not specified)."),
+				"template": {
+					Type:        "string",
+					Description: "A Go template the payload is rendered by, as an alternative to body.",
+				},
+				"template_file": {
+					Type:        "string",
+					Description: "The path of a file containing the Go template the payload is rendered by.",
+				},
+			},
+		},
+	},
+}
+
+// JSONSchema returns the JSON Schema describing the configuration format.
+func JSONSchema() ([]byte, error) {
+	return json.MarshalIndent(configSchema, "", "  ")
+}
+
+// ValidateStructure checks every configuration file against the JSON Schema describing the configuration format
+// (e.g. unknown keys or values of the wrong type).
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateStructure() []*Diagnostic {
+	var r []*Diagnostic
+	for _, src := range c.sources {
+		r = append(r, validateSource(src)...)
+	}
+	return r
+}
+
+func validateSource(src *source) []*Diagnostic {
+	if src.root == nil {
+		return nil
+	}
+	v := schemaValidator{src: src}
+	v.validate(src.root, configSchema, nil)
+	return v.diagnostics
+}
+
+type schemaValidator struct {
+	src         *source
+	diagnostics []*Diagnostic
+}
+
+func (v *schemaValidator) report(n *yamlv3.Node, path []interface{}, format string, a ...interface{}) {
+	d := &Diagnostic{Position: Position{File: v.src.file, Line: n.Line, Column: n.Column}, Rule: -1, Message: fmt.Sprintf(format, a...)}
+	if len(path) >= 2 && path[0] == "pattern_list" {
+		d.Rule = path[1].(int)
+		path = path[2:]
+	}
+	field := make([]string, len(path))
+	for i, e := range path {
+		field[i] = fmt.Sprintf("%v", e)
+	}
+	d.Field = strings.Join(field, ".")
+	v.diagnostics = append(v.diagnostics, d)
+}
+
+func resolveRef(s *jsonSchema) *jsonSchema {
+	for s.Ref != "" {
+		s = configSchema.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	}
+	return s
+}
+
+func (v *schemaValidator) validate(n *yamlv3.Node, s *jsonSchema, path []interface{}) {
+	if n.Kind == yamlv3.AliasNode {
+		n = n.Alias
+	}
+	s = resolveRef(s)
+	if len(s.OneOf) > 0 {
+		v.validateOneOf(n, s, path)
+		return
+	}
+	if s.Type != "" && !matchesType(n, s.Type) {
+		v.report(n, path, "expected a value of type '%s'; got '%s' instead", s.Type, nodeType(n))
+		return
+	}
+	switch n.Kind {
+	case yamlv3.MappingNode:
+		v.validateObject(n, s, path)
+	case yamlv3.SequenceNode:
+		if s.Items != nil {
+			for i, e := range n.Content {
+				v.validate(e, s.Items, append(path[:len(path):len(path)], i))
+			}
+		}
+	case yamlv3.ScalarNode:
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(n.Value) {
+			v.report(n, path, "expected an expression wrapped into the block marker (${…}); got '%s' instead", n.Value)
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, n.Value) {
+			v.report(n, path, "expected one of {'%s'}; got '%s' instead", strings.Join(s.Enum, "', '"), n.Value)
+		}
+		if s.Minimum != nil && n.ShortTag() == "!!int" {
+			var i int
+			if err := n.Decode(&i); err == nil && i < *s.Minimum {
+				v.report(n, path, "expected a value greater than or equal to %d; got %d instead", *s.Minimum, i)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(n *yamlv3.Node, s *jsonSchema, path []interface{}) {
+	keys := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, e := n.Content[i], n.Content[i+1]
+		keys[k.Value] = true
+		p := append(path[:len(path):len(path)], k.Value)
+		if ps, ok := s.Properties[k.Value]; ok {
+			v.validate(e, ps, p)
+			continue
+		}
+		switch a := s.AdditionalProperties.(type) {
+		case bool:
+			if !a {
+				v.report(k, path, "unknown key '%s'%s", k.Value, suggestKey(k.Value, s.Properties))
+			}
+		case *jsonSchema:
+			v.validate(e, a, p)
+		}
+	}
+	for _, k := range s.Required {
+		if !keys[k] {
+			v.report(n, path, "missing required key '%s'", k)
+		}
+	}
+}
+
+// validateOneOf validates a node against the alternatives matching its type, so that
+// the reported errors are the ones of the most likely alternative (i.e. the one with fewer errors).
+// An object carrying all the required keys of an alternative (e.g. sse) is validated against it alone.
+func (v *schemaValidator) validateOneOf(n *yamlv3.Node, s *jsonSchema, path []interface{}) {
+	for _, a := range s.OneOf {
+		if a = resolveRef(a); hasRequiredKeys(n, a) {
+			v.validate(n, a, path)
+			return
+		}
+	}
+	var types []string
+	var best []*Diagnostic
+	matched := false
+	for _, a := range s.OneOf {
+		a = resolveRef(a)
+		if !matchesType(n, a.Type) {
+			types = append(types, fmt.Sprintf("'%s'", a.Type))
+			continue
+		}
+		c := schemaValidator{src: v.src}
+		c.validate(n, a, path)
+		if !matched || len(c.diagnostics) < len(best) {
+			best = c.diagnostics
+		}
+		matched = true
+		if len(best) == 0 {
+			break
+		}
+	}
+	if !matched {
+		v.report(n, path, "expected a value of type %s; got '%s' instead", strings.Join(types, " or "), nodeType(n))
+		return
+	}
+	v.diagnostics = append(v.diagnostics, best...)
+}
+
+// hasRequiredKeys determines whether n is an object carrying all the required keys of s, if any.
+func hasRequiredKeys(n *yamlv3.Node, s *jsonSchema) bool {
+	if n.Kind != yamlv3.MappingNode || len(s.Required) == 0 {
+		return false
+	}
+	keys := make(map[string]bool)
+	for i := 0; i < len(n.Content); i += 2 {
+		keys[n.Content[i].Value] = true
+	}
+	for _, k := range s.Required {
+		if !keys[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesType(n *yamlv3.Node, t string) bool {
+	switch t {
+	case "":
+		return true
+	case "number":
+		return nodeType(n) == "integer" || nodeType(n) == "number"
+	default:
+		return nodeType(n) == t
+	}
+}
+
+func nodeType(n *yamlv3.Node) string {
+	switch n.Kind {
+	case yamlv3.MappingNode:
+		return "object"
+	case yamlv3.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+// suggestKey looks for a known key differing from the specified one just by separators or case (e.g. status-code).
+func suggestKey(key string, properties map[string]*jsonSchema) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(s))
+	}
+	var candidates []string
+	for k := range properties {
+		if normalize(k) == normalize(key) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+	return fmt.Sprintf(": did you mean '%s'?", candidates[0])
+}
+
+func containsString(a []string, s string) bool {
+	for _, e := range a {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package cfg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/mitchellh/mapstructure"
+)
+
+// DefaultScriptTimeout is the maximum execution time of a script, unless differently specified.
+const DefaultScriptTimeout = 1000 * time.Millisecond
+
+// ScriptRsp is the scripted version of a Response object.
+// Script is the JavaScript source to be executed (alternatively, it can be read from File) and it
+// returns an object made of status, headers and body fields.
+// Timeout is the maximum execution time in milliseconds:
+//
+//	rsp := ScriptRsp{Script: `return {status: 200, body: {total: 42}}`, Timeout: 500}
+type ScriptRsp struct {
+	Script  string        `mapstructure:"script"`
+	File    string        `mapstructure:"script_file"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// DecodeScriptRsp decodes a Response object into a ScriptRsp.
+// It returns nil whether the Response object is not a scripted one.
+func DecodeScriptRsp(o interface{}) (*ScriptRsp, error) {
+	var keys map[string]interface{}
+	if err := mapstructure.Decode(o, &keys); err != nil {
+		return nil, nil
+	}
+	_, script := keys["script"]
+	_, file := keys["script_file"]
+	if !script && !file {
+		return nil, nil
+	}
+	var rsp ScriptRsp
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &rsp})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(o); err != nil {
+		return nil, err
+	}
+	if script && file {
+		return nil, fmt.Errorf("'script' and 'script_file' are mutually exclusive")
+	}
+	if rsp.Timeout < 0 {
+		return nil, fmt.Errorf("timeout requires a value greater than zero")
+	}
+	return &rsp, nil
+}
+
+// Source returns the JavaScript source of the script.
+func (rsp *ScriptRsp) Source() (string, error) {
+	if rsp.File == "" {
+		return rsp.Script, nil
+	}
+	b, err := ioutil.ReadFile(rsp.File)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Compile wraps the script into a function receiving request, vars and state arguments and compiles it.
+func (rsp *ScriptRsp) Compile() (*goja.Program, error) {
+	src, err := rsp.Source()
+	if err != nil {
+		return nil, err
+	}
+	return goja.Compile(rsp.File, fmt.Sprintf("(function(request, vars, state) {\n%s\n})", src), true)
+}
+
+func (rsp *ScriptRsp) validate(loc locator, path []interface{}) []*Diagnostic {
+	if _, err := rsp.Compile(); err != nil {
+		name := "script"
+		if rsp.File != "" {
+			name = "script_file"
+		}
+		return []*Diagnostic{loc(err, field(path, name)...)}
+	}
+	return nil
+}
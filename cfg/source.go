@@ -0,0 +1,99 @@
+package cfg
+
+import (
+	"fmt"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Position represents a location within a configuration file.
+// Line and Column are 1-based and they are zero whether the location is unknown.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// source keeps track of the syntax tree of a configuration file, so that any configuration
+// element can be traced back to its originating line and column.
+// YAML being a superset of JSON, the same tree serves both syntaxes, while the tree of the other
+// formats is built from their generic representation: it can be validated against the schema
+// as well, even though the positions of its nodes are unknown.
+type source struct {
+	file string
+	root *yamlv3.Node
+}
+
+func newSource(file string, format Format, raw []byte) *source {
+	s := &source{file: file}
+	var m map[string]interface{}
+	switch format {
+	case FormatTOML:
+		m, _ = decodeTOML(raw)
+	case FormatHCL:
+		m, _ = decodeHCL(raw)
+	}
+	if m != nil {
+		var root yamlv3.Node
+		if err := root.Encode(m); err == nil {
+			s.root = &root
+		}
+		return s
+	}
+	if format != FormatJSON && format != FormatYAML {
+		return s
+	}
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(raw, &root); err == nil && len(root.Content) > 0 {
+		s.root = root.Content[0]
+	}
+	return s
+}
+
+// position looks up the node identified by path, where every element is either a mapping key (string)
+// or a sequence index (int). The position of the deepest existing node is returned.
+func (s *source) position(path ...interface{}) Position {
+	if s == nil {
+		return Position{}
+	}
+	p := Position{File: s.file}
+	n := s.root
+	for n != nil {
+		p.Line, p.Column = n.Line, n.Column
+		if len(path) == 0 {
+			break
+		}
+		n = child(n, path[0])
+		path = path[1:]
+	}
+	return p
+}
+
+func child(n *yamlv3.Node, e interface{}) *yamlv3.Node {
+	if n.Kind == yamlv3.AliasNode {
+		n = n.Alias
+	}
+	switch k := e.(type) {
+	case string:
+		if n.Kind != yamlv3.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == k {
+				return n.Content[i+1]
+			}
+		}
+	case int:
+		if n.Kind == yamlv3.SequenceNode && k >= 0 && k < len(n.Content) {
+			return n.Content[k]
+		}
+	}
+	return nil
+}
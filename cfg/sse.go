@@ -0,0 +1,80 @@
+package cfg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// SSERsp streams Events to the client as Server-Sent Events, then it keeps the connection open until the
+// client disconnects. Events are streamed Repeat further times (endlessly whether -1):
+//
+//	rsp := SSERsp{Events: []*SSEEvent{{Event: "tick", Data: "${now()}", Delay: 1000}}, Repeat: -1}
+type SSERsp struct {
+	Events []*SSEEvent `mapstructure:"events"`
+	Repeat int         `mapstructure:"repeat"`
+}
+
+// SSEEvent is a single Server-Sent Event, which is sent Delay milliseconds after the previous one.
+// ID, Event and Data can be expressions as well: values other than strings are encoded as JSON, while
+// multiline data is split into multiple data fields. Retry is the reconnection time, in milliseconds,
+// suggested to the client.
+type SSEEvent struct {
+	ID    string        `mapstructure:"id"`
+	Event string        `mapstructure:"event"`
+	Data  string        `mapstructure:"data"`
+	Retry int           `mapstructure:"retry"`
+	Delay time.Duration `mapstructure:"delay"`
+}
+
+// DecodeSSERsp decodes the Server-Sent Events version of a Response object, which wraps a SSERsp by the sse key.
+// Nil is returned whether the object is not a Server-Sent Events response.
+func DecodeSSERsp(o interface{}) (*SSERsp, error) {
+	var keys map[string]interface{}
+	if err := mapstructure.Decode(o, &keys); err != nil {
+		return nil, nil
+	}
+	sse, ok := keys["sse"]
+	if !ok {
+		return nil, nil
+	}
+	if len(keys) > 1 {
+		return nil, fmt.Errorf("'sse' cannot be specified along with other keys")
+	}
+	var rsp SSERsp
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &rsp})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(sse); err != nil {
+		return nil, err
+	}
+	return &rsp, nil
+}
+
+func (rsp *SSERsp) validate(loc locator, path []interface{}, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
+	path = field(path, "sse")
+	if rsp.Repeat < -1 {
+		r = append(r, loc(fmt.Errorf("repeat requires a value greater than or equal to zero, or -1 for endless streaming"), field(path, "repeat")...))
+	}
+	for i, e := range rsp.Events {
+		if e == nil {
+			continue
+		}
+		if e.Delay < 0 {
+			r = append(r, loc(fmt.Errorf("delay requires a value greater than or equal to zero"), field(path, "events", i, "delay")...))
+		}
+		if e.Retry < 0 {
+			r = append(r, loc(fmt.Errorf("retry requires a value greater than or equal to zero"), field(path, "events", i, "retry")...))
+		}
+		fields := []struct{ name, value string }{{"id", e.ID}, {"event", e.Event}, {"data", e.Data}}
+		for _, f := range fields {
+			if _, err := validateEvaluation(f.value, vars); err != nil {
+				r = append(r, loc(err, field(path, "events", i, f.name)...))
+			}
+		}
+	}
+	return r
+}
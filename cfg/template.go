@@ -0,0 +1,58 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"github.com/naighes/imposter/functions"
+)
+
+// templateFuncs are the helper functions available to response templates.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"now": time.Now,
+	"date_add": func(d string, t time.Time) (time.Time, error) {
+		a, err := time.ParseDuration(d)
+		if err != nil {
+			return t, err
+		}
+		return t.Add(a), nil
+	},
+	"date_format": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"uuid":          functions.UUID,
+	"random_int":    functions.RandomInt,
+	"random_string": functions.RandomString,
+	"faker":         functions.Fake,
+}
+
+// HasTemplate determines whether the body is rendered by a Go template.
+func (rsp *MatchRsp) HasTemplate() bool {
+	return rsp.Template != "" || rsp.TemplateFile != ""
+}
+
+// ParseTemplate parses the Go template the body is rendered by, either inline or read from a file.
+func (rsp *MatchRsp) ParseTemplate() (*template.Template, error) {
+	if rsp.Template != "" && rsp.TemplateFile != "" {
+		return nil, fmt.Errorf("'template' and 'template_file' are mutually exclusive")
+	}
+	if rsp.Body != "" {
+		return nil, fmt.Errorf("'body' and 'template' are mutually exclusive")
+	}
+	src := rsp.Template
+	if rsp.TemplateFile != "" {
+		b, err := ioutil.ReadFile(rsp.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		src = string(b)
+	}
+	return template.New("body").Funcs(templateFuncs).Parse(src)
+}
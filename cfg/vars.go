@@ -0,0 +1,80 @@
+package cfg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envPattern matches ${NAME} and ${NAME:-default} placeholders.
+// Only upper case identifiers are taken into account, so that they cannot be confused with expressions.
+var envPattern = regexp.MustCompile(`\$\{([A-Z_][A-Z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces any ${NAME} placeholder with the value of the corresponding environment variable.
+// A default value can be provided by the ${NAME:-default} syntax.
+// It returns an error when a variable is not set and no default value was given.
+func ExpandEnv(raw []byte) ([]byte, error) {
+	var err error
+	r := envPattern.ReplaceAllFunc(raw, func(m []byte) []byte {
+		g := envPattern.FindSubmatch(m)
+		name := string(g[1])
+		if v, ok := os.LookupEnv(name); ok {
+			return []byte(v)
+		}
+		if len(g[2]) > 0 {
+			return g[3]
+		}
+		if err == nil {
+			err = fmt.Errorf("environment variable '%s' is not set", name)
+		}
+		return m
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReadVars takes a path as an input and parses its content as a set of variables.
+// Both JSON and YAML syntaxes are supported.
+func ReadVars(varsFile string) (map[string]interface{}, error) {
+	path, err := filepath.Abs(varsFile)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err = ExpandEnv(raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", varsFile, err)
+	}
+	var r map[string]interface{}
+	if err := yaml.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("%s: %v", varsFile, err)
+	}
+	return r, nil
+}
+
+// MergeVars overrides the configuration variables by the given ones.
+func (c *Config) MergeVars(vars map[string]interface{}) {
+	if len(vars) == 0 {
+		return
+	}
+	if c.Vars == nil {
+		c.Vars = make(map[string]interface{})
+	}
+	for k, v := range vars {
+		c.Vars[k] = v
+		// variables set explicitly override the ones of virtual hosts as well
+		for _, vh := range c.VirtualHosts {
+			if _, ok := vh.Vars[k]; ok {
+				vh.Vars[k] = v
+			}
+		}
+	}
+}
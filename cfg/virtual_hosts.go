@@ -0,0 +1,94 @@
+package cfg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/naighes/imposter/functions"
+)
+
+// VirtualHost groups the rules serving requests addressed to specific hosts, which are tested in place of
+// the top-level ones. A request is addressed to a virtual host whether its Host header (or the server name
+// requested by TLS clients) is listed by Hosts, where "*.example.com" matches any subdomain of example.com,
+// or it matches any of the regular expressions listed by HostPatterns.
+// Vars override the top-level variables, while requests matching no rule are handled by DefaultResponse
+// or, when not specified, as top-level unmatched requests.
+type VirtualHost struct {
+	Hosts           []string               `json:"hosts" yaml:"hosts"`
+	HostPatterns    []string               `json:"host_patterns" yaml:"host_patterns"`
+	Defs            []*MatchDef            `json:"pattern_list" yaml:"pattern_list"`
+	Vars            map[string]interface{} `json:"vars" yaml:"vars"`
+	DefaultResponse interface{}            `json:"default_response" yaml:"default_response"`
+
+	src   *source
+	index int
+}
+
+// Label returns a description of the virtual host for logs and error messages.
+func (vh *VirtualHost) Label() string {
+	if len(vh.Hosts) > 0 {
+		return fmt.Sprintf("virtual host '%s'", vh.Hosts[0])
+	}
+	if len(vh.HostPatterns) > 0 {
+		return fmt.Sprintf("virtual host '%s'", vh.HostPatterns[0])
+	}
+	return fmt.Sprintf("virtual host #%d", vh.index)
+}
+
+// ResolveVars returns the variables the rules of the virtual host are evaluated with.
+func (vh *VirtualHost) ResolveVars(vars map[string]interface{}) map[string]interface{} {
+	r := make(map[string]interface{}, len(vars)+len(vh.Vars))
+	for k, v := range vars {
+		r[k] = v
+	}
+	for k, v := range vh.Vars {
+		r[k] = v
+	}
+	return r
+}
+
+// MatchHost determines whether the specified host name (without port) is listed by Hosts.
+func (vh *VirtualHost) MatchHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, h := range vh.Hosts {
+		h = strings.ToLower(h)
+		if strings.HasPrefix(h, "*.") {
+			if strings.HasSuffix(host, h[1:]) && len(host) > len(h)-1 {
+				return true
+			}
+		} else if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateVirtualHosts checks the virtual hosts along with their rules, trying to catch potential evaluation errors.
+// An empty array is returned whether no errors were found.
+func (c *Config) ValidateVirtualHosts(parse functions.ExpressionParser, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
+	for _, vh := range c.VirtualHosts {
+		if len(vh.Hosts) == 0 && len(vh.HostPatterns) == 0 {
+			r = append(r, vh.diagnostic(fmt.Errorf("at least one of 'hosts' and 'host_patterns' is required")))
+		}
+		for i, p := range vh.HostPatterns {
+			if _, err := regexp.Compile(p); err != nil {
+				r = append(r, vh.diagnostic(err, "host_patterns", i))
+			}
+		}
+		v := vh.ResolveVars(vars)
+		for _, def := range vh.Defs {
+			r = append(r, def.Validate(parse, v)...)
+		}
+		if vh.DefaultResponse != nil {
+			r = append(r, validateResponse(vh.diagnostic, vh.DefaultResponse, []interface{}{"default_response"}, parse, v)...)
+		}
+	}
+	return r
+}
+
+func (vh *VirtualHost) diagnostic(err error, path ...interface{}) *Diagnostic {
+	p := vh.src.position(append([]interface{}{"virtual_hosts", vh.index}, path...)...)
+	return newDiagnostic(p, -1, err, path)
+}
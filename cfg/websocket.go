@@ -0,0 +1,146 @@
+package cfg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/naighes/imposter/functions"
+)
+
+// DefaultWebSocketCloseCode is the code connections are closed by, unless differently specified.
+const DefaultWebSocketCloseCode = 1000
+
+// WebSocketRsp upgrades matching requests to WebSocket connections and runs a script of message exchanges:
+// the OnConnect messages are sent once the connection is established, every incoming message is answered
+// by the first of Replies whose Match expression holds true, Pushes are sent periodically and Close, when
+// specified, closes the connection after a delay. Messages can be expressions as well:
+//
+//	rsp := WebSocketRsp{OnConnect: []string{"hello"}, Replies: []*WebSocketReply{{Match: `${eq(websocket_message(), "ping")}`, Send: []string{"pong"}}}}
+type WebSocketRsp struct {
+	OnConnect []string          `mapstructure:"on_connect"`
+	Replies   []*WebSocketReply `mapstructure:"replies"`
+	Pushes    []*WebSocketPush  `mapstructure:"pushes"`
+	Close     *WebSocketClose   `mapstructure:"close"`
+}
+
+// WebSocketReply sends the Send messages whether an incoming message satisfies the Match boolean expression,
+// which can access the message by websocket_message(); the connection is then closed whether Close is specified.
+type WebSocketReply struct {
+	Match string          `mapstructure:"match"`
+	Send  []string        `mapstructure:"send"`
+	Close *WebSocketClose `mapstructure:"close"`
+}
+
+// WebSocketPush sends Message every Interval milliseconds, up to Count times (forever when not specified).
+type WebSocketPush struct {
+	Message  string        `mapstructure:"message"`
+	Interval time.Duration `mapstructure:"interval"`
+	Count    int           `mapstructure:"count"`
+}
+
+// WebSocketClose closes a connection by Code (DefaultWebSocketCloseCode when not specified) and Reason,
+// After the specified milliseconds.
+type WebSocketClose struct {
+	After  time.Duration `mapstructure:"after"`
+	Code   int           `mapstructure:"code"`
+	Reason string        `mapstructure:"reason"`
+}
+
+// DecodeWebSocketRsp decodes the WebSocket version of a Response object, which wraps a WebSocketRsp by the websocket key.
+// Nil is returned whether the object is not a WebSocket response.
+func DecodeWebSocketRsp(o interface{}) (*WebSocketRsp, error) {
+	var keys map[string]interface{}
+	if err := mapstructure.Decode(o, &keys); err != nil {
+		return nil, nil
+	}
+	ws, ok := keys["websocket"]
+	if !ok {
+		return nil, nil
+	}
+	if len(keys) > 1 {
+		return nil, fmt.Errorf("'websocket' cannot be specified along with other keys")
+	}
+	var rsp WebSocketRsp
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &rsp})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(ws); err != nil {
+		return nil, err
+	}
+	return &rsp, nil
+}
+
+func (rsp *WebSocketRsp) validate(loc locator, path []interface{}, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
+	path = field(path, "websocket")
+	r = append(r, validateMessages(loc, field(path, "on_connect"), rsp.OnConnect, vars)...)
+	for i, reply := range rsp.Replies {
+		if reply == nil {
+			continue
+		}
+		if err := validateRuleExpression(reply.Match, vars); err != nil {
+			r = append(r, loc(err, field(path, "replies", i, "match")...))
+		}
+		r = append(r, validateMessages(loc, field(path, "replies", i, "send"), reply.Send, vars)...)
+		if err := reply.Close.validate(); err != nil {
+			r = append(r, loc(err, field(path, "replies", i, "close")...))
+		}
+	}
+	for i, push := range rsp.Pushes {
+		if push == nil {
+			continue
+		}
+		if push.Interval <= 0 {
+			r = append(r, loc(fmt.Errorf("interval requires a value greater than zero"), field(path, "pushes", i, "interval")...))
+		}
+		if push.Count < 0 {
+			r = append(r, loc(fmt.Errorf("count requires a value greater than or equal to zero"), field(path, "pushes", i, "count")...))
+		}
+		if _, err := validateEvaluation(push.Message, vars); err != nil {
+			r = append(r, loc(err, field(path, "pushes", i, "message")...))
+		}
+	}
+	if err := rsp.Close.validate(); err != nil {
+		r = append(r, loc(err, field(path, "close")...))
+	}
+	return r
+}
+
+func validateMessages(loc locator, path []interface{}, messages []string, vars map[string]interface{}) []*Diagnostic {
+	var r []*Diagnostic
+	for i, m := range messages {
+		if _, err := validateEvaluation(m, vars); err != nil {
+			r = append(r, loc(err, field(path, i)...))
+		}
+	}
+	return r
+}
+
+func (c *WebSocketClose) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.After < 0 {
+		return fmt.Errorf("after requires a value greater than or equal to zero")
+	}
+	// codes reserved by the protocol cannot be sent by endpoints
+	if c.Code != 0 && (c.Code < 1000 || c.Code >= 5000 || c.Code == 1004 || c.Code == 1005 || c.Code == 1006 || c.Code == 1015) {
+		return fmt.Errorf("'%d' is not a valid close code", c.Code)
+	}
+	return nil
+}
+
+// ParseMessages parses the specified messages, which can be expressions as well.
+func ParseMessages(messages []string, parse functions.ExpressionParser) ([]functions.Expression, error) {
+	r := make([]functions.Expression, len(messages))
+	for i, m := range messages {
+		e, err := parse(m)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = e
+	}
+	return r, nil
+}
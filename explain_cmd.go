@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/naighes/imposter/cfg"
+	"github.com/naighes/imposter/handlers"
+)
+
+func explainCmd() command {
+	fs := flag.NewFlagSet("imposter explain", flag.ExitOnError)
+	opts := explainOpts{}
+	fs.StringVar(&opts.configFile, "config-file", "", "The configuration file")
+	fs.StringVar(&opts.configFormat, "config-format", "", "The configuration format, one of {'json', 'yaml', 'toml', 'hcl'}: detected by file extension when not specified")
+	fs.StringVar(&opts.requestFile, "request", "stdin", "The file containing the HTTP request to be explained (e.g. GET /path HTTP/1.1, followed by headers and body)")
+	fs.StringVar(&opts.tags, "tags", "", "A comma separated list of tags: just the rules labeled by at least one of them are loaded")
+	fs.BoolVar(&opts.jsonEncoded, "json", false, "Enable JSON output instead of plain text")
+	opts.vars.register(fs)
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return explainExec(&opts)
+	}}
+}
+
+type explainOpts struct {
+	configFile   string
+	configFormat string
+	requestFile  string
+	tags         string
+	jsonEncoded  bool
+	vars         varsOpts
+}
+
+func explainExec(opts *explainOpts) error {
+	format, err := cfg.ParseFormat(opts.configFormat)
+	if err != nil {
+		return err
+	}
+	config, err := cfg.ReadConfigFormat(opts.configFile, format)
+	if err != nil {
+		return fmt.Errorf("could not load configuration: %v", err)
+	}
+	if err := opts.vars.apply(config); err != nil {
+		return err
+	}
+	config.FilterTags(parseTags(opts.tags))
+	router, err := handlers.NewRouterHandler(config)
+	if err != nil {
+		return fmt.Errorf("could not load configuration: %v", err)
+	}
+	var raw []byte
+	if opts.requestFile == "stdin" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		raw, err = ioutil.ReadFile(opts.requestFile)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read request: %v", err)
+	}
+	r, err := parseRequest(raw)
+	if err != nil {
+		return fmt.Errorf("could not parse request: %v", err)
+	}
+	x := router.Explain(r)
+	if opts.jsonEncoded {
+		b, _ := json.MarshalIndent(x, "", "  ")
+		fmt.Printf("%s\n", string(b))
+	} else {
+		fmt.Print(x.String())
+	}
+	if x.Match == "" {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// parseRequest parses an HTTP request in wire format. The protocol version can be omitted
+// from the request line and, without a Content-Length header, the body extends to the end of the content.
+func parseRequest(raw []byte) (*http.Request, error) {
+	raw = bytes.TrimLeft(raw, "\r\n")
+	line := raw
+	rest := []byte{}
+	if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+		line, rest = raw[:i], raw[i:]
+	}
+	var b bytes.Buffer
+	b.Write(bytes.TrimRight(line, "\r"))
+	if len(strings.Fields(string(line))) == 2 {
+		b.WriteString(" HTTP/1.1")
+	}
+	b.Write(rest)
+	if !bytes.Contains(rest, []byte("\n\n")) && !bytes.Contains(rest, []byte("\n\r\n")) {
+		b.WriteString("\n\n")
+	}
+	br := bufio.NewReader(&b)
+	r, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	if r.ContentLength <= 0 && len(r.TransferEncoding) == 0 {
+		body, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+	if r.URL.Host != "" && r.Host == "" {
+		r.Host = r.URL.Host
+	}
+	return r, nil
+}
@@ -0,0 +1,81 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type bodyMatchesJSONFunction struct {
+	doc     Expression
+	options Expression
+}
+
+func newBodyMatchesJSONFunction(args []Expression) (Expression, error) {
+	l := len(args)
+	switch l {
+	case 1:
+		r := bodyMatchesJSONFunction{doc: args[0]}
+		return r, nil
+	case 2:
+		r := bodyMatchesJSONFunction{doc: args[0], options: args[1]}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("function 'body_matches_json' is expecting one or two arguments of type 'string'; found %d argument(s) instead", l)
+	}
+}
+
+func (f bodyMatchesJSONFunction) evaluate(g func(Expression) (interface{}, error), ctx *EvaluationContext) (interface{}, error) {
+	a, err := g(f.doc)
+	if err != nil {
+		return false, err
+	}
+	s, ok := a.(string)
+	if !ok {
+		return false, fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", a)
+	}
+	expected, err := parseJSONDocument(s)
+	if err != nil {
+		return false, err
+	}
+	var options jsonMatchOptions
+	if f.options != nil {
+		b, err := g(f.options)
+		if err != nil {
+			return false, err
+		}
+		o, ok := b.(string)
+		if !ok {
+			return false, fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", b)
+		}
+		if options, err = parseJSONMatchOptions(o); err != nil {
+			return false, fmt.Errorf("evaluation error: %v", err)
+		}
+	}
+	body, err := readBody(ctx.Req)
+	if err != nil {
+		return false, err
+	}
+	var actual interface{}
+	if err := json.Unmarshal(body, &actual); err != nil {
+		return false, nil
+	}
+	return matchJSON(expected, actual, options), nil
+}
+
+func (f bodyMatchesJSONFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, ctx)
+}
+
+func (f bodyMatchesJSONFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, ctx)
+}
@@ -0,0 +1,72 @@
+package functions
+
+import (
+	"sort"
+)
+
+type builtin struct {
+	new         func(args []Expression) (Expression, error)
+	signature   string
+	description string
+}
+
+// Builtin describes a built-in function.
+type Builtin struct {
+	Name        string
+	Signature   string
+	Description string
+}
+
+var builtins = map[string]builtin{
+	"link":                            {newLinkFunction, "link(url: string) -> HTTPRsp", "Forwards a client to a new URL."},
+	"redirect":                        {newRedirectFunction, "redirect(url: string, status_code: int) -> HTTPRsp", "Redirects a client to a new URL with the specified status_code (it must be a 3XX value)."},
+	"file":                            {newFileFunction, "file(path: string) -> string", "Reads the content of a file into a string."},
+	"var":                             {newVarFunction, "var(name: string) -> string", "Reads the content of a variable with the specified name into a string."},
+	"and":                             {newAndFunction, "and(arg1: bool, arg2: bool, …) -> bool", "Evaluates all arguments by using the AND logical operator."},
+	"or":                              {newOrFunction, "or(arg1: bool, arg2: bool, …) -> bool", "Evaluates all arguments by using the OR logical operator."},
+	"not":                             {newNotFunction, "not(arg: bool) -> bool", "Negates its argument."},
+	"request_http_header":             {newRequestHTTPHeaderFunction, "request_http_header(name: string) -> string", "Returns the value of the HTTP header with the specified name for the current request."},
+	"eq":                              {newEqFunction, "eq(arg1: any, arg2: any) -> bool", "Determines whether the two specified arguments are equal."},
+	"ne":                              {newNeFunction, "ne(arg1: any, arg2: any) -> bool", "Determines whether the two specified arguments are not equal."},
+	"contains":                        {newContainsFunction, "contains(source: string, value: string) -> bool", "Determines whether value substring occurs within this source string."},
+	"request_url":                     {newRequestURLFunction, "request_url() -> string", "Returns the URL for the current request."},
+	"request_url_path":                {newRequestURLPathFunction, "request_url_path() -> string", "Returns the path component of the URL for the current request."},
+	"request_url_query":               {newRequestURLQueryFunction, "request_url_query(name: string) -> string", "Returns the first value associated with the given name or the whole query when no name is given."},
+	"request_http_method":             {newRequestHTTPMethodFunction, "request_http_method() -> string", "Returns the HTTP method for the current request."},
+	"request_http_host":               {newRequestHTTPHostFunction, "request_http_host() -> string", "Returns the HTTP Host for the current request."},
+	"request_http_proto":              {newRequestHTTPProtoFunction, "request_http_proto() -> string", "Returns the protocol version (e.g. HTTP/1.1 or HTTP/2.0) of the current request."},
+	"request_client_cert_subject":     {newRequestClientCertSubjectFunction, "request_client_cert_subject() -> string", "Returns the subject (e.g. CN=client,O=Acme) of the verified TLS client certificate, or an empty string when there is none."},
+	"request_client_cert_sans":        {newRequestClientCertSANsFunction, "request_client_cert_sans() -> array", "Returns the subject alternative names (DNS names, emails, IPs and URIs) of the verified TLS client certificate."},
+	"request_client_cert_fingerprint": {newRequestClientCertFingerprintFunction, "request_client_cert_fingerprint() -> string", "Returns the hex encoded SHA-256 fingerprint of the verified TLS client certificate, or an empty string when there is none."},
+	"websocket_message":               {newWebSocketMessageFunction, "websocket_message() -> string", "Returns the incoming WebSocket message replies of a websocket response are matched against."},
+	"regex_match":                     {newRegexMatchFunction, "regex_match(source: string, pattern: string) -> bool", "Determines whether the specified source string matches the regular expression pattern."},
+	"in":                              {newInFunction, "in(source: array, item: string|bool|int|float64) -> bool", "Determines whether the specified item exists as an element within the source array."},
+	"to_string":                       {newToStringFunction, "to_string(obj: any) -> string", "Returns a string that represents obj."},
+	"env":                             {newEnvFunction, "env(name: string, default: string) -> string", "Returns the value of the environment variable with the specified name or default when it is not set."},
+	"uuid":                            {newUUIDFunction, "uuid() -> string", "Returns a random (version 4) UUID."},
+	"random_int":                      {newRandomIntFunction, "random_int(min: int, max: int) -> int", "Returns a random integer between min and max (both included)."},
+	"random_string":                   {newRandomStringFunction, "random_string(length: int) -> string", "Returns a random alphanumeric string of the specified length."},
+	"faker":                           {newFakerFunction, "faker(kind: string) -> string", "Returns a random yet realistic value of the specified kind (e.g. name, email, phone, …)."},
+	"now":                             {newNowFunction, "now(layout: string) -> string", "Returns the current time formatted by the specified Go layout (RFC 3339 when not specified)."},
+	"body_matches_json":               {newBodyMatchesJSONFunction, "body_matches_json(doc: string, options: string) -> bool", "Determines whether the JSON body of the current request contains the partial document doc; options is a pipe separated list of flags from {ignore_array_order, placeholders, type_only}."},
+	"date_add":                        {newDateAddFunction, "date_add(date: string, duration: string, layout: string) -> string", "Adds a duration (e.g. 24h or -90m) to a date formatted by the specified Go layout (RFC 3339 when not specified)."},
+}
+
+// Builtins returns the description of all built-in functions, sorted by name.
+func Builtins() []Builtin {
+	r := make([]Builtin, 0, len(builtins))
+	for name, b := range builtins {
+		r = append(r, Builtin{Name: name, Signature: b.signature, Description: b.description})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name < r[j].Name })
+	return r
+}
+
+// LookupBuiltin returns the description of the built-in function with the specified name.
+func LookupBuiltin(name string) (Builtin, bool) {
+	b, ok := builtins[name]
+	if !ok {
+		return Builtin{}, false
+	}
+	return Builtin{Name: name, Signature: b.signature, Description: b.description}, true
+}
@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+)
+
+// clientCertificate returns the verified certificate the client of the specified request authenticated with, if any.
+func clientCertificate(req *http.Request) *x509.Certificate {
+	if req == nil || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.TLS.VerifiedChains[0][0]
+}
+
+// certificateSANs lists the DNS names, email addresses, IP addresses and URIs of a certificate.
+func certificateSANs(c *x509.Certificate) []interface{} {
+	r := []interface{}{}
+	for _, e := range c.DNSNames {
+		r = append(r, e)
+	}
+	for _, e := range c.EmailAddresses {
+		r = append(r, e)
+	}
+	for _, e := range c.IPAddresses {
+		r = append(r, e.String())
+	}
+	for _, e := range c.URIs {
+		r = append(r, e.String())
+	}
+	return r
+}
+
+// certificateFingerprint returns the hex encoded SHA-256 digest of a certificate.
+func certificateFingerprint(c *x509.Certificate) string {
+	d := sha256.Sum256(c.Raw)
+	return hex.EncodeToString(d[:])
+}
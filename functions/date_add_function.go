@@ -0,0 +1,79 @@
+package functions
+
+import (
+	"fmt"
+	"time"
+)
+
+type dateAddFunction struct {
+	date     Expression
+	duration Expression
+	layout   Expression
+}
+
+func newDateAddFunction(args []Expression) (Expression, error) {
+	l := len(args)
+	switch l {
+	case 2:
+		r := dateAddFunction{date: args[0], duration: args[1]}
+		return r, nil
+	case 3:
+		r := dateAddFunction{date: args[0], duration: args[1], layout: args[2]}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("function 'date_add' is expecting two or three arguments of type 'string'; found %d argument(s) instead", l)
+	}
+}
+
+func (f dateAddFunction) evaluate(g func(Expression) (interface{}, error), strict bool) (interface{}, error) {
+	a, err := g(f.date)
+	if err != nil {
+		return "", err
+	}
+	date, ok := a.(string)
+	if !ok {
+		return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", a)
+	}
+	b, err := g(f.duration)
+	if err != nil {
+		return "", err
+	}
+	s, ok := b.(string)
+	if !ok {
+		return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", b)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return "", fmt.Errorf("evaluation error: %v", err)
+	}
+	layout, err := evaluateLayout(g, f.layout)
+	if err != nil {
+		return "", err
+	}
+	t, err := time.Parse(layout, date)
+	if err != nil {
+		if strict {
+			return "", fmt.Errorf("evaluation error: %v", err)
+		}
+		return date, nil
+	}
+	return t.Add(d).Format(layout), nil
+}
+
+func (f dateAddFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, true)
+}
+
+func (f dateAddFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, false)
+}
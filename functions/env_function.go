@@ -0,0 +1,71 @@
+package functions
+
+import (
+	"fmt"
+	"os"
+)
+
+type envFunction struct {
+	name         Expression
+	defaultValue Expression
+}
+
+func newEnvFunction(args []Expression) (Expression, error) {
+	l := len(args)
+	switch l {
+	case 1:
+		r := envFunction{name: args[0]}
+		return r, nil
+	case 2:
+		r := envFunction{name: args[0], defaultValue: args[1]}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("function 'env' is expecting one or two arguments of type 'string'; found %d argument(s) instead", l)
+	}
+}
+
+func (f envFunction) evaluate(g func(Expression) (interface{}, error), strict bool) (interface{}, error) {
+	a, err := g(f.name)
+	if err != nil {
+		return "", err
+	}
+	name, ok := a.(string)
+	if !ok {
+		return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", a)
+	}
+	var def string
+	if f.defaultValue != nil {
+		b, err := g(f.defaultValue)
+		if err != nil {
+			return "", err
+		}
+		if def, ok = b.(string); !ok {
+			return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", b)
+		}
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	if f.defaultValue == nil && strict {
+		return "", fmt.Errorf("evaluation error: environment variable '%s' is not set", name)
+	}
+	return def, nil
+}
+
+func (f envFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, true)
+}
+
+func (f envFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, false)
+}
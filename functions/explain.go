@@ -0,0 +1,105 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trace records the evaluation of an expression along with the evaluation of its sub-expressions
+// (literals are omitted).
+type Trace struct {
+	Expression string      `json:"expression"`
+	Value      interface{} `json:"value,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Children   []*Trace    `json:"children,omitempty"`
+}
+
+// Explain evaluates an expression and every sub-expression it is made of, so that the reason
+// for its value can be inspected.
+func Explain(e Expression, ctx *EvaluationContext) *Trace {
+	t := &Trace{Expression: ExpressionString(e)}
+	v, err := e.Evaluate(ctx)
+	if err != nil {
+		t.Error = err.Error()
+	} else {
+		t.Value = v
+	}
+	var children []Expression
+	switch n := e.(type) {
+	case *function:
+		children = n.args
+	case *ifElse:
+		children = []Expression{n.guard, n.left, n.right}
+	}
+	for _, c := range children {
+		switch c.(type) {
+		case *function, *ifElse:
+			t.Children = append(t.Children, Explain(c, ctx))
+		}
+	}
+	return t
+}
+
+// Score returns the number of boolean sub-expressions (the expression itself included) evaluating to true,
+// along with the total number of boolean sub-expressions.
+func (t *Trace) Score() (int, int) {
+	matched, total := 0, 0
+	if b, ok := t.Value.(bool); ok || t.Error != "" {
+		total++
+		if b {
+			matched++
+		}
+	}
+	for _, c := range t.Children {
+		m, n := c.Score()
+		matched += m
+		total += n
+	}
+	return matched, total
+}
+
+// Failures returns the innermost sub-expressions evaluating to false or raising an error, which are
+// the ones responsible for a mismatch.
+func (t *Trace) Failures() []*Trace {
+	var r []*Trace
+	for _, c := range t.Children {
+		r = append(r, c.Failures()...)
+	}
+	if len(r) > 0 {
+		return r
+	}
+	if b, ok := t.Value.(bool); (ok && !b) || t.Error != "" {
+		return []*Trace{t}
+	}
+	return nil
+}
+
+// ExpressionString returns the textual representation of an expression.
+func ExpressionString(e Expression) string {
+	switch n := e.(type) {
+	case *function:
+		args := make([]string, len(n.args))
+		for i, a := range n.args {
+			args[i] = ExpressionString(a)
+		}
+		return fmt.Sprintf("%s(%s)", n.name, strings.Join(args, ", "))
+	case *ifElse:
+		return fmt.Sprintf("if (%s) %s else %s", ExpressionString(n.guard), ExpressionString(n.left), ExpressionString(n.right))
+	case *arrayIdentity:
+		elements := make([]string, len(n.elements))
+		for i, a := range n.elements {
+			elements[i] = ExpressionString(a)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+	case *stringIdentity:
+		return fmt.Sprintf(`"%s"`, n.value)
+	case *integerIdentity:
+		return n.value
+	case *floatIdentity:
+		return n.value
+	case *boolIdentity:
+		return n.value
+	default:
+		return fmt.Sprintf("%v", e)
+	}
+}
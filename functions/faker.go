@@ -0,0 +1,88 @@
+package functions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	fakeFirstNames = []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth", "David", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen", "Nicola", "Giulia", "Marco", "Chiara"}
+	fakeLastNames  = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Jackson", "Martin", "Lee", "Rossi", "Bianchi", "Ferrari", "Romano"}
+	fakeDomains    = []string{"example.com", "example.net", "example.org", "mail.test", "inbox.test"}
+	fakeCompanies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Vandelay", "Stark", "Wayne", "Wonka", "Soylent"}
+	fakeSuffixes   = []string{"Inc.", "LLC", "Ltd.", "Group", "Corp."}
+	fakeCities     = []string{"New York", "London", "Paris", "Berlin", "Madrid", "Rome", "Milan", "Tokyo", "Sydney", "Toronto", "Amsterdam", "Lisbon"}
+	fakeCountries  = []string{"United States", "United Kingdom", "France", "Germany", "Spain", "Italy", "Japan", "Australia", "Canada", "Netherlands", "Portugal"}
+	fakeStreets    = []string{"Main St", "High St", "Park Ave", "Oak St", "Maple Ave", "Cedar Rd", "Elm St", "Lake View Dr", "Hill Rd", "Church St"}
+	fakeWords      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua"}
+)
+
+// fakers maps every supported kind of fake data to its generator; the random lock is held while generators run.
+var fakers = map[string]func() string{
+	"first_name": func() string { return randomElement(fakeFirstNames) },
+	"last_name":  func() string { return randomElement(fakeLastNames) },
+	"name": func() string {
+		return fmt.Sprintf("%s %s", randomElement(fakeFirstNames), randomElement(fakeLastNames))
+	},
+	"username": fakeUsername,
+	"email": func() string {
+		return fmt.Sprintf("%s@%s", fakeUsername(), randomElement(fakeDomains))
+	},
+	"phone": func() string {
+		return fmt.Sprintf("+1-%03d-%03d-%04d", 200+random.source.Intn(800), random.source.Intn(1000), random.source.Intn(10000))
+	},
+	"company": func() string {
+		return fmt.Sprintf("%s %s", randomElement(fakeCompanies), randomElement(fakeSuffixes))
+	},
+	"city":    func() string { return randomElement(fakeCities) },
+	"country": func() string { return randomElement(fakeCountries) },
+	"address": func() string {
+		return fmt.Sprintf("%d %s", 1+random.source.Intn(999), randomElement(fakeStreets))
+	},
+	"word": func() string { return randomElement(fakeWords) },
+	"sentence": func() string {
+		w := make([]string, 5+random.source.Intn(8))
+		for i := range w {
+			w[i] = randomElement(fakeWords)
+		}
+		s := strings.Join(w, " ")
+		return strings.ToUpper(s[:1]) + s[1:] + "."
+	},
+}
+
+func randomElement(a []string) string {
+	return a[random.source.Intn(len(a))]
+}
+
+func fakeUsername() string {
+	return fmt.Sprintf("%s.%s%d", strings.ToLower(randomElement(fakeFirstNames)), strings.ToLower(randomElement(fakeLastNames)), random.source.Intn(100))
+}
+
+// FakerKinds returns the supported kinds of fake data, sorted by name.
+func FakerKinds() []string {
+	r := make([]string, 0, len(fakers))
+	for k := range fakers {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func checkFakerKind(kind string) error {
+	if _, ok := fakers[kind]; !ok {
+		return fmt.Errorf("'%s' is not a valid kind of fake data: select one from {'%s'}", kind, strings.Join(FakerKinds(), "', '"))
+	}
+	return nil
+}
+
+// Fake returns a random yet realistic value of the specified kind (e.g. name, email, …).
+func Fake(kind string) (string, error) {
+	if err := checkFakerKind(kind); err != nil {
+		return "", err
+	}
+	f := fakers[kind]
+	random.lock.Lock()
+	defer random.lock.Unlock()
+	return f(), nil
+}
@@ -0,0 +1,58 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type fakerFunction struct {
+	kind Expression
+}
+
+func newFakerFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 1 {
+		return nil, fmt.Errorf("function 'faker' is expecting one argument of type 'string'; found %d argument(s) instead", l)
+	}
+	r := fakerFunction{kind: args[0]}
+	return r, nil
+}
+
+// evaluate returns an empty string, with no random value being consumed, whether generate is false.
+func (f fakerFunction) evaluate(g func(Expression) (interface{}, error), generate bool) (interface{}, error) {
+	a, err := g(f.kind)
+	if err != nil {
+		return "", err
+	}
+	kind, ok := a.(string)
+	if !ok {
+		return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", a)
+	}
+	if !generate {
+		if err := checkFakerKind(kind); err != nil {
+			return "", fmt.Errorf("evaluation error: %v", err)
+		}
+		return "", nil
+	}
+	r, err := Fake(kind)
+	if err != nil {
+		return "", fmt.Errorf("evaluation error: %v", err)
+	}
+	return r, nil
+}
+
+func (f fakerFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, true)
+}
+
+func (f fakerFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, false)
+}
@@ -14,6 +14,10 @@ import (
 type EvaluationContext struct {
 	Vars map[string]interface{}
 	Req  *http.Request
+	// Params collects the named groups captured by regex_match, when not nil.
+	Params map[string]string
+	// Message is the incoming WebSocket message, when evaluating the replies of a websocket response.
+	Message string
 }
 
 type ExpressionParser = func(string) (Expression, error)
@@ -130,50 +134,11 @@ func (e arrayIdentity) evaluate(f func(Expression) (interface{}, error)) (interf
 type Evaluate func(*EvaluationContext) (interface{}, error)
 
 func getEvaluationFunc(name string) (func(args []Expression) (Expression, error), error) {
-	var b func(args []Expression) (Expression, error)
-	switch name {
-	case "link":
-		b = newLinkFunction
-	case "redirect":
-		b = newRedirectFunction
-	case "file":
-		b = newFileFunction
-	case "var":
-		b = newVarFunction
-	case "and":
-		b = newAndFunction
-	case "or":
-		b = newOrFunction
-	case "not":
-		b = newNotFunction
-	case "request_http_header":
-		b = newRequestHTTPHeaderFunction
-	case "eq":
-		b = newEqFunction
-	case "ne":
-		b = newNeFunction
-	case "contains":
-		b = newContainsFunction
-	case "request_url":
-		b = newRequestURLFunction
-	case "request_url_path":
-		b = newRequestURLPathFunction
-	case "request_url_query":
-		b = newRequestURLQueryFunction
-	case "request_http_method":
-		b = newRequestHTTPMethodFunction
-	case "request_http_host":
-		b = newRequestHTTPHostFunction
-	case "regex_match":
-		b = newRegexMatchFunction
-	case "in":
-		b = newInFunction
-	case "to_string":
-		b = newToStringFunction
-	default:
+	b, ok := builtins[name]
+	if !ok {
 		return nil, fmt.Errorf("could not find a built-in function with name '%s'", name)
 	}
-	return b, nil
+	return b.new, nil
 }
 
 func (e function) Evaluate(ctx *EvaluationContext) (interface{}, error) {
@@ -1,10 +1,18 @@
 package functions
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -609,6 +617,59 @@ func TestRequestHost(t *testing.T) {
 	}
 }
 
+func TestRequestProto(t *testing.T) {
+	token, err := ParseExpression(`${eq(request_http_proto(), "HTTP/2.0")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for proto, expected := range map[string]bool{"HTTP/2.0": true, "HTTP/1.1": false} {
+		e, err := token.Evaluate(&EvaluationContext{Req: &http.Request{Proto: proto}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if e != expected {
+			t.Errorf("expected value '%t' for %s; got '%v' instead", expected, proto, e)
+		}
+	}
+}
+
+func TestRequestClientCert(t *testing.T) {
+	cert := &x509.Certificate{
+		Raw:      []byte("certificate"),
+		Subject:  pkix.Name{CommonName: "partner", Organization: []string{"Acme"}},
+		DNSNames: []string{"partner.example.com"},
+	}
+	req := &http.Request{TLS: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: req}
+	sum := sha256.Sum256(cert.Raw)
+	expected := map[string]string{
+		`${request_client_cert_subject()}`:                                                "CN=partner,O=Acme",
+		`${if (in(request_client_cert_sans(), "partner.example.com")) "ok" else "wrong"}`: "ok",
+		`${request_client_cert_fingerprint()}`:                                            hex.EncodeToString(sum[:]),
+	}
+	for str, v := range expected {
+		token, err := ParseExpression(str)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		e, err := token.Evaluate(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if e != v {
+			t.Errorf("expected value '%s' for %s; got '%v' instead", v, str, e)
+		}
+	}
+	token, _ := ParseExpression(`${request_client_cert_subject()}`)
+	if e, err := token.Evaluate(&EvaluationContext{Req: &http.Request{}}); err != nil || e != "" {
+		t.Errorf("expected an empty subject without client certificates; got '%v' (%v) instead", e, err)
+	}
+}
+
 func TestArrayInTrue(t *testing.T) {
 	str := `${
 		if (in(["a", "b", "c"], "b"))
@@ -666,3 +727,198 @@ func TestArrayInFalse(t *testing.T) {
 		return
 	}
 }
+
+func TestEnvVariable(t *testing.T) {
+	const expected = "imposter.local"
+	os.Setenv("IMPOSTER_TEST_HOST", expected)
+	defer os.Unsetenv("IMPOSTER_TEST_HOST")
+	token, err := ParseExpression(`${env("IMPOSTER_TEST_HOST", "localhost")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	e, err := token.Evaluate(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if e != expected {
+		t.Errorf("expected value '%s'; got '%v'", expected, e)
+		return
+	}
+}
+
+func TestEnvVariableDefault(t *testing.T) {
+	const expected = "localhost"
+	os.Unsetenv("IMPOSTER_TEST_HOST")
+	token, err := ParseExpression(`${env("IMPOSTER_TEST_HOST", "localhost")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	e, err := token.Evaluate(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if e != expected {
+		t.Errorf("expected value '%s'; got '%v'", expected, e)
+		return
+	}
+}
+
+func TestEnvVariableNotSet(t *testing.T) {
+	os.Unsetenv("IMPOSTER_TEST_HOST")
+	token, err := ParseExpression(`${env("IMPOSTER_TEST_HOST")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	if _, err := token.Evaluate(ctx); err == nil {
+		t.Errorf("an error was expected for a missing environment variable")
+		return
+	}
+	if _, err := token.Test(ctx); err != nil {
+		t.Errorf("no errors were expected while testing: %v", err)
+		return
+	}
+}
+
+func TestRandomFunctionsWithSeed(t *testing.T) {
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	for _, str := range []string{`${random_int(1, 1000)}`, `${random_string(8)}`, `${faker("email")}`, `${uuid()}`} {
+		token, err := ParseExpression(str)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		Seed(42)
+		e1, err := token.Evaluate(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		Seed(42)
+		// testing an expression must not consume random values
+		if _, err := token.Test(ctx); err != nil {
+			t.Error(err)
+			return
+		}
+		e2, err := token.Evaluate(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if e1 != e2 {
+			t.Errorf("expected the same value of '%s' for the same seed; got '%v' and '%v'", str, e1, e2)
+			return
+		}
+	}
+}
+
+func TestRandomIntRange(t *testing.T) {
+	token, err := ParseExpression(`${random_int(3, 5)}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	for i := 0; i < 50; i++ {
+		e, err := token.Evaluate(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if v, ok := e.(int); !ok || v < 3 || v > 5 {
+			t.Errorf("expected an 'int' value between 3 and 5; got '%v'", e)
+			return
+		}
+	}
+	if e, err := token.Test(ctx); err != nil || reflect.TypeOf(e).Kind() != reflect.Int {
+		t.Errorf("expected an 'int' value while testing; got '%v' (%v)", e, err)
+	}
+}
+
+func TestFakerUnknownKind(t *testing.T) {
+	token, err := ParseExpression(`${faker("spaceship")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	if _, err := token.Test(ctx); err == nil {
+		t.Errorf("an error was expected for an unknown kind of fake data")
+	}
+}
+
+func TestDateAdd(t *testing.T) {
+	const expected = "2020-01-02"
+	token, err := ParseExpression(`${date_add("2020-01-01", "24h", "2006-01-02")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	e, err := token.Evaluate(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if e != expected {
+		t.Errorf("expected value '%s'; got '%v'", expected, e)
+		return
+	}
+}
+
+func TestBodyMatchesJSON(t *testing.T) {
+	const body = `{"id": 42, "name": "imposter", "tags": ["mock", "http"], "owner": {"name": "naighes", "active": true}}`
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`${body_matches_json("{\"name\": \"imposter\", \"owner\": {\"active\": true}}")}`, true},
+		{`${body_matches_json("{\"name\": \"other\"}")}`, false},
+		{`${body_matches_json("{\"tags\": [\"http\", \"mock\"]}")}`, false},
+		{`${body_matches_json("{\"tags\": [\"http\", \"mock\"]}", "ignore_array_order")}`, true},
+		{`${body_matches_json("{\"id\": \"{{number}}\", \"name\": \"{{regex:^imp}}\"}", "placeholders")}`, true},
+		{`${body_matches_json("{\"id\": \"{{string}}\"}", "placeholders")}`, false},
+		{`${body_matches_json("{\"id\": 0, \"name\": \"\"}", "type_only")}`, true},
+	}
+	for _, test := range tests {
+		token, err := ParseExpression(test.expression)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req, _ := http.NewRequest("POST", "http://fak.eurl/", strings.NewReader(body))
+		ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: req}
+		e, err := token.Evaluate(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if e != test.expected {
+			t.Errorf("expected value '%v' for '%s'; got '%v'", test.expected, test.expression, e)
+			return
+		}
+		if b, _ := ioutil.ReadAll(req.Body); string(b) != body {
+			t.Errorf("expected the request body to be readable after evaluation")
+			return
+		}
+	}
+}
+
+func TestBodyMatchesJSONInvalidDocument(t *testing.T) {
+	token, err := ParseExpression(`${body_matches_json("{invalid")}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := &EvaluationContext{Vars: make(map[string]interface{}), Req: &http.Request{}}
+	if _, err := token.Test(ctx); err == nil {
+		t.Errorf("an error was expected for an invalid JSON document")
+	}
+}
@@ -0,0 +1,163 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type jsonMatchOptions int
+
+const (
+	ignoreArrayOrder jsonMatchOptions = 1 << iota
+	placeholders
+	typeOnly
+)
+
+var placeholderPattern = regexp.MustCompile(`^\{\{\s*(regex:([\s\S]*)|string|number|boolean|array|object|null|any)\s*\}\}$`)
+
+func parseJSONMatchOptions(config string) (jsonMatchOptions, error) {
+	if config == "" {
+		return 0, nil
+	}
+	m := map[string]jsonMatchOptions{
+		"ignore_array_order": ignoreArrayOrder,
+		"placeholders":       placeholders,
+		"type_only":          typeOnly,
+	}
+	var r jsonMatchOptions
+	for _, v := range strings.Split(config, "|") {
+		o, ok := m[v]
+		if !ok {
+			return 0, fmt.Errorf("'%s' is not a valid option: select multiple values from {'ignore_array_order', 'placeholders', 'type_only'} separated by pipe (|)", v)
+		}
+		r = r | o
+	}
+	return r, nil
+}
+
+// parseJSONDocument decodes a JSON document; since string literals keep their escape sequences,
+// escaped double quotes are unescaped whether the document cannot be decoded as it is.
+func parseJSONDocument(s string) (interface{}, error) {
+	var r interface{}
+	err := json.Unmarshal([]byte(s), &r)
+	if err == nil {
+		return r, nil
+	}
+	if e := json.Unmarshal([]byte(strings.Replace(s, `\"`, `"`, -1)), &r); e == nil {
+		return r, nil
+	}
+	return nil, fmt.Errorf("evaluation error: invalid JSON document: %v", err)
+}
+
+// readBody reads the body of a request, replacing it with a copy so that it can be read again.
+func readBody(r *http.Request) ([]byte, error) {
+	if r == nil || r.Body == nil {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// matchJSON determines whether the actual document contains the expected one: objects are allowed
+// to carry further keys, while arrays are required to have the same length.
+func matchJSON(expected, actual interface{}, options jsonMatchOptions) bool {
+	if s, ok := expected.(string); ok && options&placeholders == placeholders {
+		if m := placeholderPattern.FindStringSubmatch(s); m != nil {
+			return matchPlaceholder(m, actual)
+		}
+	}
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range e {
+			w, ok := a[k]
+			if !ok || !matchJSON(v, w, options) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		if options&ignoreArrayOrder == ignoreArrayOrder {
+			return matchUnordered(e, a, options)
+		}
+		for i := range e {
+			if !matchJSON(e[i], a[i], options) {
+				return false
+			}
+		}
+		return true
+	default:
+		if options&typeOnly == typeOnly {
+			return jsonType(expected) == jsonType(actual)
+		}
+		return expected == actual
+	}
+}
+
+// matchUnordered pairs every expected element with a distinct actual one by backtracking.
+func matchUnordered(expected, actual []interface{}, options jsonMatchOptions) bool {
+	used := make([]bool, len(actual))
+	var match func(i int) bool
+	match = func(i int) bool {
+		if i == len(expected) {
+			return true
+		}
+		for j := range actual {
+			if !used[j] && matchJSON(expected[i], actual[j], options) {
+				used[j] = true
+				if match(i + 1) {
+					return true
+				}
+				used[j] = false
+			}
+		}
+		return false
+	}
+	return match(0)
+}
+
+func matchPlaceholder(m []string, actual interface{}) bool {
+	if strings.HasPrefix(m[1], "regex:") {
+		s, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		reg, err := regexp.Compile(m[2])
+		return err == nil && reg.MatchString(s)
+	}
+	return m[1] == "any" || m[1] == jsonType(actual)
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
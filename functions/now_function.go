@@ -0,0 +1,66 @@
+package functions
+
+import (
+	"fmt"
+	"time"
+)
+
+type nowFunction struct {
+	layout Expression
+}
+
+func newNowFunction(args []Expression) (Expression, error) {
+	l := len(args)
+	switch l {
+	case 0:
+		r := nowFunction{}
+		return r, nil
+	case 1:
+		r := nowFunction{layout: args[0]}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("function 'now' is expecting one or no arguments of type 'string'; found %d argument(s) instead", l)
+	}
+}
+
+// evaluateLayout evaluates an optional layout argument, defaulting to RFC 3339.
+func evaluateLayout(g func(Expression) (interface{}, error), e Expression) (string, error) {
+	if e == nil {
+		return time.RFC3339, nil
+	}
+	a, err := g(e)
+	if err != nil {
+		return "", err
+	}
+	layout, ok := a.(string)
+	if !ok {
+		return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'string'", a)
+	}
+	return layout, nil
+}
+
+func (f nowFunction) evaluate(g func(Expression) (interface{}, error)) (interface{}, error) {
+	layout, err := evaluateLayout(g, f.layout)
+	if err != nil {
+		return "", err
+	}
+	return time.Now().Format(layout), nil
+}
+
+func (f nowFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g)
+}
+
+func (f nowFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g)
+}
@@ -0,0 +1,58 @@
+package functions
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const randomStringAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// random is the source of any random value, shared by built-in functions and response templates.
+var random = struct {
+	source *rand.Rand
+	lock   sync.Mutex
+}{source: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// Seed initializes the source of random values, so that the same sequence of values is generated across runs.
+func Seed(seed int64) {
+	random.lock.Lock()
+	defer random.lock.Unlock()
+	random.source = rand.New(rand.NewSource(seed))
+}
+
+// RandomInt returns a random integer in the closed interval [min, max].
+func RandomInt(min, max int) (int, error) {
+	if min > max {
+		return 0, fmt.Errorf("expected min to be lower than or equal to max; got %d and %d instead", min, max)
+	}
+	random.lock.Lock()
+	defer random.lock.Unlock()
+	return min + random.source.Intn(max-min+1), nil
+}
+
+// RandomString returns a random alphanumeric string of length n.
+func RandomString(n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("expected a non negative length; got %d instead", n)
+	}
+	random.lock.Lock()
+	defer random.lock.Unlock()
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = randomStringAlphabet[random.source.Intn(len(randomStringAlphabet))]
+	}
+	return string(b), nil
+}
+
+// UUID returns a random (version 4) UUID.
+func UUID() string {
+	random.lock.Lock()
+	defer random.lock.Unlock()
+	var b [16]byte
+	random.source.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
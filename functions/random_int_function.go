@@ -0,0 +1,67 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type randomIntFunction struct {
+	min Expression
+	max Expression
+}
+
+func newRandomIntFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 2 {
+		return nil, fmt.Errorf("function 'random_int' is expecting two arguments of type 'int'; found %d argument(s) instead", l)
+	}
+	r := randomIntFunction{min: args[0], max: args[1]}
+	return r, nil
+}
+
+// evaluate returns min, with no random value being consumed, whether generate is false.
+func (f randomIntFunction) evaluate(g func(Expression) (interface{}, error), generate bool) (interface{}, error) {
+	a, err := g(f.min)
+	if err != nil {
+		return 0, err
+	}
+	min, ok := a.(int)
+	if !ok {
+		return 0, fmt.Errorf("evaluation error: cannot convert value '%v' to 'int'", a)
+	}
+	b, err := g(f.max)
+	if err != nil {
+		return 0, err
+	}
+	max, ok := b.(int)
+	if !ok {
+		return 0, fmt.Errorf("evaluation error: cannot convert value '%v' to 'int'", b)
+	}
+	if !generate {
+		if min > max {
+			return 0, fmt.Errorf("evaluation error: expected min to be lower than or equal to max; got %d and %d instead", min, max)
+		}
+		return min, nil
+	}
+	r, err := RandomInt(min, max)
+	if err != nil {
+		return 0, fmt.Errorf("evaluation error: %v", err)
+	}
+	return r, nil
+}
+
+func (f randomIntFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, true)
+}
+
+func (f randomIntFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, false)
+}
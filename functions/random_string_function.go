@@ -0,0 +1,58 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type randomStringFunction struct {
+	length Expression
+}
+
+func newRandomStringFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 1 {
+		return nil, fmt.Errorf("function 'random_string' is expecting one argument of type 'int'; found %d argument(s) instead", l)
+	}
+	r := randomStringFunction{length: args[0]}
+	return r, nil
+}
+
+// evaluate returns an empty string, with no random value being consumed, whether generate is false.
+func (f randomStringFunction) evaluate(g func(Expression) (interface{}, error), generate bool) (interface{}, error) {
+	a, err := g(f.length)
+	if err != nil {
+		return "", err
+	}
+	n, ok := a.(int)
+	if !ok {
+		return "", fmt.Errorf("evaluation error: cannot convert value '%v' to 'int'", a)
+	}
+	if !generate {
+		if n < 0 {
+			return "", fmt.Errorf("evaluation error: expected a non negative length; got %d instead", n)
+		}
+		return "", nil
+	}
+	r, err := RandomString(n)
+	if err != nil {
+		return "", fmt.Errorf("evaluation error: %v", err)
+	}
+	return r, nil
+}
+
+func (f randomStringFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Evaluate(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, true)
+}
+
+func (f randomStringFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	g := func(ctx *EvaluationContext) func(Expression) (interface{}, error) {
+		return func(expression Expression) (interface{}, error) {
+			return expression.Test(ctx)
+		}
+	}(ctx)
+	return f.evaluate(g, false)
+}
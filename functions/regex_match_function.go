@@ -40,7 +40,18 @@ func (f regexMatchFunction) Evaluate(ctx *EvaluationContext) (interface{}, error
 	if err != nil {
 		return false, err
 	}
-	return reg.MatchString(left), nil
+	m := reg.FindStringSubmatch(left)
+	if m == nil {
+		return false, nil
+	}
+	if ctx.Params != nil {
+		for i, name := range reg.SubexpNames() {
+			if name != "" {
+				ctx.Params[name] = m[i]
+			}
+		}
+	}
+	return true, nil
 }
 
 func (f regexMatchFunction) Test(ctx *EvaluationContext) (interface{}, error) {
@@ -0,0 +1,27 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type requestClientCertFingerprintFunction struct {
+}
+
+func newRequestClientCertFingerprintFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 0 {
+		return nil, fmt.Errorf("function 'request_client_cert_fingerprint' is expecting no arguments; found %d argument(s) instead", l)
+	}
+	r := requestClientCertFingerprintFunction{}
+	return r, nil
+}
+
+func (f requestClientCertFingerprintFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	if c := clientCertificate(ctx.Req); c != nil {
+		return certificateFingerprint(c), nil
+	}
+	return "", nil
+}
+
+func (f requestClientCertFingerprintFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	return f.Evaluate(ctx)
+}
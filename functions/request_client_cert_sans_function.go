@@ -0,0 +1,27 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type requestClientCertSANsFunction struct {
+}
+
+func newRequestClientCertSANsFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 0 {
+		return nil, fmt.Errorf("function 'request_client_cert_sans' is expecting no arguments; found %d argument(s) instead", l)
+	}
+	r := requestClientCertSANsFunction{}
+	return r, nil
+}
+
+func (f requestClientCertSANsFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	if c := clientCertificate(ctx.Req); c != nil {
+		return certificateSANs(c), nil
+	}
+	return []interface{}{}, nil
+}
+
+func (f requestClientCertSANsFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	return f.Evaluate(ctx)
+}
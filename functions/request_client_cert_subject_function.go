@@ -0,0 +1,27 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type requestClientCertSubjectFunction struct {
+}
+
+func newRequestClientCertSubjectFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 0 {
+		return nil, fmt.Errorf("function 'request_client_cert_subject' is expecting no arguments; found %d argument(s) instead", l)
+	}
+	r := requestClientCertSubjectFunction{}
+	return r, nil
+}
+
+func (f requestClientCertSubjectFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	if c := clientCertificate(ctx.Req); c != nil {
+		return c.Subject.String(), nil
+	}
+	return "", nil
+}
+
+func (f requestClientCertSubjectFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	return f.Evaluate(ctx)
+}
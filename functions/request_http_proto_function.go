@@ -0,0 +1,24 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type requestHTTPProtoFunction struct {
+}
+
+func newRequestHTTPProtoFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 0 {
+		return nil, fmt.Errorf("function 'request_http_proto' is expecting no arguments; found %d argument(s) instead", l)
+	}
+	r := requestHTTPProtoFunction{}
+	return r, nil
+}
+
+func (f requestHTTPProtoFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	return ctx.Req.Proto, nil
+}
+
+func (f requestHTTPProtoFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	return f.Evaluate(ctx)
+}
@@ -0,0 +1,24 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type uuidFunction struct {
+}
+
+func newUUIDFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 0 {
+		return nil, fmt.Errorf("function 'uuid' is expecting no arguments; found %d argument(s) instead", l)
+	}
+	r := uuidFunction{}
+	return r, nil
+}
+
+func (f uuidFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	return UUID(), nil
+}
+
+func (f uuidFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	return "", nil
+}
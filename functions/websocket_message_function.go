@@ -0,0 +1,24 @@
+package functions
+
+import (
+	"fmt"
+)
+
+type webSocketMessageFunction struct {
+}
+
+func newWebSocketMessageFunction(args []Expression) (Expression, error) {
+	if l := len(args); l != 0 {
+		return nil, fmt.Errorf("function 'websocket_message' is expecting no arguments; found %d argument(s) instead", l)
+	}
+	r := webSocketMessageFunction{}
+	return r, nil
+}
+
+func (f webSocketMessageFunction) Evaluate(ctx *EvaluationContext) (interface{}, error) {
+	return ctx.Message, nil
+}
+
+func (f webSocketMessageFunction) Test(ctx *EvaluationContext) (interface{}, error) {
+	return f.Evaluate(ctx)
+}
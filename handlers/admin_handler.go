@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminPathPrefix is the path prefix of the endpoints controlling a running imPOSTer instance.
+const AdminPathPrefix = "/_imposter"
+
+// serveAdmin handles the endpoints controlling the router:
+//
+//	DELETE /_imposter/responses         resets the response counters of all rules
+//	DELETE /_imposter/responses/{rule}  resets the response counters of the rules with the specified name or index
+//
+// Rules of virtual hosts are included.
+func (router *RouterHandler) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	p := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, AdminPathPrefix), "/"), "/")
+	if p[0] != "responses" || len(p) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	routes := append([]*route{}, router.routes...)
+	for _, vh := range router.hosts {
+		routes = append(routes, vh.router.routes...)
+	}
+	if len(p) == 1 {
+		for _, route := range routes {
+			route.responses.reset()
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	i, err := strconv.Atoi(p[1])
+	found := false
+	for _, route := range routes {
+		if route.name == p[1] || (err == nil && route.index == i) {
+			route.responses.reset()
+			found = true
+		}
+	}
+	if found {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Error(w, fmt.Sprintf("could not find a rule with name or index '%s'", p[1]), http.StatusNotFound)
+}
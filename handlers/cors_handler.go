@@ -2,18 +2,101 @@ package handlers
 
 import (
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/naighes/imposter/cfg"
 )
 
-// CorsHandler is a type providing basic support for Cross-Origin Resource Sharing.
+// CorsHandler is a type providing support for Cross-Origin Resource Sharing to the wrapped Handler.
+// Preflight requests from allowed origins are answered without reaching the wrapped Handler, while
+// requests from any other origin are handled as if CORS was not supported.
 type CorsHandler struct {
-	Enabled bool
+	Handler  http.Handler
+	config   *cfg.CorsConfig
+	patterns []*regexp.Regexp
+}
+
+// NewCorsHandler builds a new CorsHandler wrapping h, by the specified policy.
+func NewCorsHandler(h http.Handler, config *cfg.CorsConfig) (*CorsHandler, error) {
+	c := &CorsHandler{Handler: h, config: config}
+	for _, p := range config.AllowedOriginPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	return c, nil
 }
 
 func (h *CorsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !h.Enabled {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	allowOrigin, ok := h.allowOrigin(origin)
+	if !ok {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	if h.config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !isPreflight(r) {
+		if len(h.config.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(h.config.ExposedHeaders, ", "))
+		}
+		h.Handler.ServeHTTP(w, r)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if len(h.config.AllowedMethods) == 0 {
+		w.Header().Set("Access-Control-Allow-Methods", method)
+	} else {
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(h.config.AllowedMethods, ", "))
+	}
+	if len(h.config.AllowedHeaders) == 0 {
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+	} else {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(h.config.AllowedHeaders, ", "))
+	}
+	if h.config.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(h.config.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for the specified origin, if allowed.
+// The wildcard is not allowed along with credentials, so the origin is echoed instead.
+func (h *CorsHandler) allowOrigin(origin string) (string, bool) {
+	for _, o := range h.config.AllowedOrigins {
+		if o == "*" {
+			if h.config.AllowCredentials {
+				return origin, true
+			}
+			return "*", true
+		}
+		if strings.EqualFold(o, origin) {
+			return origin, true
+		}
+	}
+	for _, re := range h.patterns {
+		if re.MatchString(origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
 }
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+func corsRequest(method string, origin string) *http.Request {
+	r := httptest.NewRequest(method, "/items", nil)
+	r.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		r.Header.Set("Access-Control-Request-Method", "PUT")
+		r.Header.Set("Access-Control-Request-Headers", "X-Token")
+	}
+	return r
+}
+
+func TestCorsHandlerPreflight(t *testing.T) {
+	config := &cfg.CorsConfig{
+		AllowedOriginPatterns: []string{`^https://[a-z]+\.example\.com$`},
+		AllowedMethods:        []string{"GET", "PUT"},
+		MaxAge:                600,
+		AllowCredentials:      true,
+	}
+	h, err := NewCorsHandler(http.NotFoundHandler(), config)
+	if err != nil {
+		t.Fatalf("NewCorsHandler raised an error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, corsRequest(http.MethodOptions, "https://app.example.com"))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status code %d; got %d instead", http.StatusNoContent, w.Code)
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, PUT",
+		"Access-Control-Allow-Headers":     "X-Token",
+		"Access-Control-Max-Age":           "600",
+	}
+	for k, v := range expected {
+		if a := w.Header().Get(k); a != v {
+			t.Errorf("expected header '%s' to be '%s'; got '%s' instead", k, v, a)
+		}
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, corsRequest(http.MethodOptions, "https://evil.com"))
+	if w.Code != http.StatusNotFound || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected a disallowed origin to reach the wrapped handler without CORS headers; got %d instead", w.Code)
+	}
+	if v := w.Header().Get("Vary"); v != "Origin" {
+		t.Errorf("expected header 'Vary' to be 'Origin'; got '%s' instead", v)
+	}
+}
+
+func TestCorsHandlerWildcard(t *testing.T) {
+	for _, credentials := range []bool{false, true} {
+		config := &cfg.CorsConfig{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Total"}, AllowCredentials: credentials}
+		h, _ := NewCorsHandler(http.NotFoundHandler(), config)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, corsRequest(http.MethodGet, "http://localhost:3000"))
+		expected := "*"
+		if credentials {
+			expected = "http://localhost:3000"
+		}
+		if o := w.Header().Get("Access-Control-Allow-Origin"); o != expected {
+			t.Errorf("expected allowed origin '%s' (credentials: %t); got '%s' instead", expected, credentials, o)
+		}
+		if e := w.Header().Get("Access-Control-Expose-Headers"); e != "X-Total" {
+			t.Errorf("expected exposed headers 'X-Total'; got '%s' instead", e)
+		}
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected the request to reach the wrapped handler; got %d instead", w.Code)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/naighes/imposter/functions"
+)
+
+// Explanation reports how a request was matched against every rule, in the order rules are tested.
+type Explanation struct {
+	Request string             `json:"request"`
+	Host    string             `json:"host,omitempty"`
+	Match   string             `json:"match,omitempty"`
+	Rules   []*RuleExplanation `json:"rules"`
+	Closest []string           `json:"closest,omitempty"`
+}
+
+// RuleExplanation reports how a request was matched against a single rule.
+// Score is the ratio of boolean sub-expressions evaluating to true, while Failures
+// lists the innermost sub-expressions evaluating to false (or raising an error).
+// Exhausted is set whether the rule has no responses left, so that matching requests are not served by it.
+type RuleExplanation struct {
+	Rule      string           `json:"rule"`
+	Source    string           `json:"source,omitempty"`
+	Matched   bool             `json:"matched"`
+	Exhausted bool             `json:"exhausted,omitempty"`
+	Score     float64          `json:"score"`
+	Failures  []string         `json:"failures,omitempty"`
+	Trace     *functions.Trace `json:"trace"`
+}
+
+// Explain evaluates every rule against the specified request, without affecting the state of the router
+// (e.g. the counters of response sequences). Just the rules of the virtual host the request is addressed to
+// are evaluated, if any.
+func (router *RouterHandler) Explain(r *http.Request) *Explanation {
+	x := &Explanation{Request: fmt.Sprintf("%s %s", r.Method, r.URL.String())}
+	rules := router.dispatch(r)
+	for _, vh := range router.hosts {
+		if vh.router == rules {
+			x.Host = vh.label
+		}
+	}
+	for _, route := range rules.routes {
+		ctx := &functions.EvaluationContext{Vars: rules.vars, Req: r, Params: make(map[string]string)}
+		t := functions.Explain(route.expression, ctx)
+		e := &RuleExplanation{Rule: route.label(), Source: route.source, Trace: t}
+		e.Matched, _ = t.Value.(bool)
+		if m, n := t.Score(); n > 0 {
+			e.Score = float64(m) / float64(n)
+		}
+		for _, f := range t.Failures() {
+			if f.Error != "" {
+				e.Failures = append(e.Failures, fmt.Sprintf("%s: %s", f.Expression, f.Error))
+			} else {
+				e.Failures = append(e.Failures, f.Expression)
+			}
+		}
+		if e.Matched && route.responses.exhausted() {
+			e.Exhausted = true
+		} else if e.Matched && x.Match == "" {
+			x.Match = e.Rule
+		}
+		x.Rules = append(x.Rules, e)
+	}
+	if x.Match == "" {
+		closest := make([]*RuleExplanation, len(x.Rules))
+		copy(closest, x.Rules)
+		sort.SliceStable(closest, func(i, j int) bool {
+			return closest[i].Score > closest[j].Score
+		})
+		for _, e := range closest {
+			x.Closest = append(x.Closest, fmt.Sprintf("%s (score %.2f)", e.Rule, e.Score))
+		}
+	}
+	return x
+}
+
+func (x *Explanation) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n", x.Request)
+	if x.Host != "" {
+		fmt.Fprintf(&b, "addressed to %s\n", x.Host)
+	}
+	if x.Match != "" {
+		fmt.Fprintf(&b, "matched by %s\n", x.Match)
+	} else {
+		fmt.Fprintf(&b, "no matching rules\n")
+	}
+	for _, e := range x.Rules {
+		fmt.Fprintf(&b, "\n%s", e.Rule)
+		if e.Source != "" {
+			fmt.Fprintf(&b, " at %s", e.Source)
+		}
+		fmt.Fprintf(&b, ": matched=%t score=%.2f", e.Matched, e.Score)
+		if e.Exhausted {
+			fmt.Fprintf(&b, " (not served: no responses left)")
+		}
+		fmt.Fprintf(&b, "\n")
+		writeTrace(&b, e.Trace, 1)
+		for _, f := range e.Failures {
+			fmt.Fprintf(&b, "  false: %s\n", f)
+		}
+	}
+	if len(x.Closest) > 0 {
+		fmt.Fprintf(&b, "\nclosest matches:\n")
+		for i, c := range x.Closest {
+			fmt.Fprintf(&b, "  %d. %s\n", i+1, c)
+		}
+	}
+	return b.String()
+}
+
+func writeTrace(b *bytes.Buffer, t *functions.Trace, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if t.Error != "" {
+		fmt.Fprintf(b, "%s%s -> error: %s\n", indent, t.Expression, t.Error)
+	} else {
+		fmt.Fprintf(b, "%s%s -> %v\n", indent, t.Expression, t.Value)
+	}
+	for _, c := range t.Children {
+		writeTrace(b, c, depth+1)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+// newFallbackHandler builds the handler of the requests matching no rule, if any.
+func newFallbackHandler(config *cfg.Config, vars map[string]interface{}) (http.Handler, error) {
+	switch {
+	case config.DefaultResponse != nil:
+		f, err := HandleFunc(config.DefaultResponse, vars)
+		if err != nil {
+			return nil, err
+		}
+		return http.HandlerFunc(f), nil
+	case config.Upstream != "":
+		u, err := cfg.ParseUpstream(config.Upstream)
+		if err != nil {
+			return nil, err
+		}
+		proxy := httputil.NewSingleHostReverseProxy(u)
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			r.Host = u.Host
+		}
+		return proxy, nil
+	case config.Strict:
+		return http.HandlerFunc(strictHandler), nil
+	default:
+		return nil, nil
+	}
+}
+
+type unmatchedRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Path    string              `json:"path"`
+	Host    string              `json:"host"`
+	Query   map[string][]string `json:"query"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// strictHandler answers requests matching no rule by a 501 status code along with their details.
+func strictHandler(w http.ResponseWriter, r *http.Request) {
+	rsp := struct {
+		Error   string           `json:"error"`
+		Request unmatchedRequest `json:"request"`
+	}{
+		Error: "no rule matches the request",
+		Request: unmatchedRequest{
+			Method:  r.Method,
+			URL:     r.URL.String(),
+			Path:    r.URL.Path,
+			Host:    r.Host,
+			Query:   r.URL.Query(),
+			Headers: r.Header,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotImplemented)
+	json.NewEncoder(w).Encode(&rsp)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naighes/imposter/cfg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// gRPC status codes used by the handler itself.
+const (
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// maxGRPCMessageSize is the largest request message accepted, as by default by gRPC servers.
+const maxGRPCMessageSize = 4 << 20
+
+// grpcStatusError is an error answered by a specific status code, rather than INTERNAL.
+type grpcStatusError struct {
+	code    int
+	message string
+}
+
+func (e *grpcStatusError) Error() string {
+	return e.message
+}
+
+// GRPCServices maps the paths of gRPC methods (e.g. /helloworld.Greeter/SayHello) to their descriptors.
+type GRPCServices map[string]protoreflect.MethodDescriptor
+
+// LoadGRPCServices loads the services declared by the specified .proto files and descriptor sets.
+func LoadGRPCServices(c *cfg.GRPCConfig) (GRPCServices, error) {
+	methods, err := c.LoadMethods()
+	if err != nil {
+		return nil, err
+	}
+	return GRPCServices(methods), nil
+}
+
+type grpcMethodKey struct{}
+
+// GRPCHandler decodes the calls to the declared gRPC services before they reach the wrapped Handler:
+// the request message replaces the body by its JSON mapping, so that it can be matched by rules
+// (e.g. by body_matches_json), while the method is retained for the response to be encoded.
+// Calls to undeclared methods, as well as client-streaming calls, are answered by UNIMPLEMENTED.
+type GRPCHandler struct {
+	Handler  http.Handler
+	Services GRPCServices
+}
+
+func (h *GRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isGRPC(r) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+	method, ok := h.Services[r.URL.Path]
+	if !ok {
+		writeGRPCStatus(w, grpcUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path))
+		return
+	}
+	if method.IsStreamingClient() {
+		writeGRPCStatus(w, grpcUnimplemented, fmt.Sprintf("client-streaming method %s is not supported", r.URL.Path))
+		return
+	}
+	msg := dynamicpb.NewMessage(method.Input())
+	if err := readGRPCMessage(r.Body, msg); err != nil {
+		code := grpcInternal
+		if e, ok := err.(*grpcStatusError); ok {
+			code = e.code
+		}
+		writeGRPCStatus(w, code, err.Error())
+		return
+	}
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		writeGRPCStatus(w, grpcInternal, err.Error())
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), grpcMethodKey{}, method))
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	h.Handler.ServeHTTP(w, r)
+}
+
+func isGRPC(r *http.Request) bool {
+	t := r.Header.Get("Content-Type")
+	return t == "application/grpc" || strings.HasPrefix(t, "application/grpc+") || strings.HasPrefix(t, "application/grpc;")
+}
+
+// readGRPCMessage reads a single length-prefixed message.
+func readGRPCMessage(r io.Reader, msg proto.Message) error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return fmt.Errorf("could not read the request message: %v", err)
+	}
+	if prefix[0] != 0 {
+		return fmt.Errorf("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxGRPCMessageSize {
+		return &grpcStatusError{code: grpcResourceExhausted, message: fmt.Sprintf("the request message is larger than %d bytes", maxGRPCMessageSize)}
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("could not read the request message: %v", err)
+	}
+	return proto.Unmarshal(b, msg)
+}
+
+// writeGRPCMessage writes a single length-prefixed message.
+func writeGRPCMessage(w io.Writer, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(b)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// writeGRPCStatus answers a call by the specified status alone.
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	setGRPCStatus(w, code, message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// setGRPCStatus sets the trailers terminating a call by the specified status.
+func setGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeGRPCMessage(message))
+	}
+}
+
+// encodeGRPCMessage percent-encodes a status message, as required by the gRPC protocol.
+func encodeGRPCMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+type grpcHTTPHandler struct {
+	content *cfg.GRPCRsp
+}
+
+func (h grpcHTTPHandler) handleFunc() (func(http.ResponseWriter, *http.Request), error) {
+	code, err := cfg.ParseGRPCStatus(h.content.Status)
+	if err != nil {
+		return nil, err
+	}
+	content := h.content
+	return func(w http.ResponseWriter, r *http.Request) {
+		method, ok := r.Context().Value(grpcMethodKey{}).(protoreflect.MethodDescriptor)
+		if !ok {
+			writeError(w, fmt.Errorf("the request is not a call to a declared gRPC method"))
+			return
+		}
+		messages := content.Stream
+		if content.Body != nil {
+			messages = []interface{}{content.Body}
+		} else if messages == nil && code == 0 && !method.IsStreamingServer() {
+			// a successful unary call requires a message
+			messages = []interface{}{map[string]interface{}{}}
+		}
+		if len(messages) > 1 && !method.IsStreamingServer() {
+			writeGRPCStatus(w, grpcInternal, fmt.Sprintf("method %s is not server-streaming", r.URL.Path))
+			return
+		}
+		encoded := make([]proto.Message, len(messages))
+		for i, m := range messages {
+			msg, err := grpcMessage(method.Output(), m)
+			if err != nil {
+				writeGRPCStatus(w, grpcInternal, fmt.Sprintf("could not encode message #%d: %v", i, err))
+				return
+			}
+			encoded[i] = msg
+		}
+		for k, v := range content.Headers {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		flusher, _ := w.(http.Flusher)
+		for i, msg := range encoded {
+			if i > 0 && content.Interval > 0 {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(content.Interval * time.Millisecond):
+				}
+			}
+			if err := writeGRPCMessage(w, msg); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		for k, v := range content.Trailers {
+			w.Header().Set(http.TrailerPrefix+k, v)
+		}
+		if len(encoded) == 0 {
+			writeGRPCStatus(w, code, content.Message)
+		} else {
+			setGRPCStatus(w, code, content.Message)
+		}
+	}, nil
+}
+
+// grpcMessage converts the JSON mapping of a message into a message of the specified type.
+func grpcMessage(desc protoreflect.MessageDescriptor, v interface{}) (proto.Message, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(desc)
+	if err := protojson.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naighes/imposter/cfg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const greeterProto = `syntax = "proto3";
+package test;
+
+service Greeter {
+  rpc SayHello (HelloRequest) returns (HelloReply);
+  rpc SayHellos (HelloRequest) returns (stream HelloReply);
+}
+
+message HelloRequest {
+  string name = 1;
+}
+
+message HelloReply {
+  string message = 1;
+}
+`
+
+func newGreeterHandler(t *testing.T, defs []*cfg.MatchDef) (*GRPCHandler, GRPCServices) {
+	dir, err := ioutil.TempDir("", "imposter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "greeter.proto")
+	if err := ioutil.WriteFile(file, []byte(greeterProto), 0644); err != nil {
+		t.Fatal(err)
+	}
+	services, err := LoadGRPCServices(&cfg.GRPCConfig{ProtoFiles: []string{file}})
+	if err != nil {
+		t.Fatalf("could not load services: %v", err)
+	}
+	router, err := NewRouterHandler(&cfg.Config{Defs: defs})
+	if err != nil {
+		t.Fatalf("cannot create a new instance of NewRouterHandler: %v", err)
+	}
+	return &GRPCHandler{Handler: router, Services: services}, services
+}
+
+func grpcCall(h http.Handler, services GRPCServices, path string, name string) *http.Response {
+	msg := dynamicpb.NewMessage(services["/test.Greeter/SayHello"].Input())
+	protojson.Unmarshal([]byte(`{"name":"`+name+`"}`), msg)
+	var body bytes.Buffer
+	writeGRPCMessage(&body, msg)
+	r := httptest.NewRequest("POST", path, &body)
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestGRPCUnary(t *testing.T) {
+	defs := []*cfg.MatchDef{
+		{
+			RuleExpression: `${body_matches_json("{\"name\": \"nobody\"}")}`,
+			Response:       map[string]interface{}{"grpc": map[string]interface{}{"status": "NOT_FOUND", "message": "no such user: nobody"}},
+		},
+		{
+			RuleExpression: `${eq(request_url_path(), "/test.Greeter/SayHello")}`,
+			Response: map[string]interface{}{"grpc": map[string]interface{}{
+				"body":     map[string]interface{}{"message": "hello"},
+				"trailers": map[string]interface{}{"x-served-by": "imposter"},
+			}},
+		},
+	}
+	h, services := newGreeterHandler(t, defs)
+	rsp := grpcCall(h, services, "/test.Greeter/SayHello", "john")
+	b, _ := ioutil.ReadAll(rsp.Body)
+	if s := rsp.Trailer.Get("Grpc-Status"); s != "0" {
+		t.Fatalf("expected status OK; got '%s' (%s) instead", s, rsp.Trailer.Get("Grpc-Message"))
+	}
+	if s := rsp.Trailer.Get("X-Served-By"); s != "imposter" {
+		t.Errorf("expected trailer 'imposter'; got '%s' instead", s)
+	}
+	reply := dynamicpb.NewMessage(services["/test.Greeter/SayHello"].Output())
+	if err := readGRPCMessage(bytes.NewReader(b), reply); err != nil {
+		t.Fatalf("could not decode the reply: %v", err)
+	}
+	if s := reply.Get(reply.Descriptor().Fields().ByName("message")).String(); s != "hello" {
+		t.Errorf("expected message 'hello'; got '%s' instead", s)
+	}
+	rsp = grpcCall(h, services, "/test.Greeter/SayHello", "nobody")
+	if s := rsp.Trailer.Get("Grpc-Status"); s != "5" {
+		t.Errorf("expected status NOT_FOUND; got '%s' instead", s)
+	}
+	if s := rsp.Trailer.Get("Grpc-Message"); s != "no such user: nobody" {
+		t.Errorf("unexpected status message '%s'", s)
+	}
+}
+
+func TestGRPCServerStreaming(t *testing.T) {
+	defs := []*cfg.MatchDef{
+		{
+			RuleExpression: `${eq(request_url_path(), "/test.Greeter/SayHellos")}`,
+			Response: map[string]interface{}{"grpc": map[string]interface{}{
+				"stream": []interface{}{map[string]interface{}{"message": "one"}, map[string]interface{}{"message": "two"}},
+			}},
+		},
+	}
+	h, services := newGreeterHandler(t, defs)
+	rsp := grpcCall(h, services, "/test.Greeter/SayHellos", "john")
+	b, _ := ioutil.ReadAll(rsp.Body)
+	body := bytes.NewReader(b)
+	for _, expected := range []string{"one", "two"} {
+		reply := dynamicpb.NewMessage(services["/test.Greeter/SayHellos"].Output())
+		if err := readGRPCMessage(body, reply); err != nil {
+			t.Fatalf("could not decode the reply: %v", err)
+		}
+		if s := reply.Get(reply.Descriptor().Fields().ByName("message")).String(); s != expected {
+			t.Errorf("expected message '%s'; got '%s' instead", expected, s)
+		}
+	}
+	if body.Len() != 0 {
+		t.Errorf("expected just two messages to be streamed")
+	}
+	if s := rsp.Trailer.Get("Grpc-Status"); s != "0" {
+		t.Errorf("expected status OK; got '%s' instead", s)
+	}
+}
+
+func TestGRPCUnknownMethod(t *testing.T) {
+	h, services := newGreeterHandler(t, nil)
+	rsp := grpcCall(h, services, "/test.Greeter/SayGoodbye", "john")
+	if s := rsp.Trailer.Get("Grpc-Status"); s != "12" {
+		t.Errorf("expected status UNIMPLEMENTED; got '%s' instead", s)
+	}
+}
+
+func TestGRPCMessageTooLarge(t *testing.T) {
+	h, _ := newGreeterHandler(t, nil)
+	// the length prefix alone declares a message larger than the limit
+	r := httptest.NewRequest("POST", "/test.Greeter/SayHello", bytes.NewReader([]byte{0, 0xff, 0xff, 0xff, 0xff}))
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if s := w.Result().Trailer.Get("Grpc-Status"); s != "8" {
+		t.Errorf("expected status RESOURCE_EXHAUSTED; got '%s' instead", s)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -56,7 +57,7 @@ type matchRspHTTPHandler struct {
 
 func (h matchRspHTTPHandler) handleFunc(parse functions.ExpressionParser) (func(http.ResponseWriter, *http.Request), error) {
 	rsp := h.content
-	e1, err := parse(rsp.Body)
+	body, err := parseBody(rsp, parse)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +72,7 @@ func (h matchRspHTTPHandler) handleFunc(parse functions.ExpressionParser) (func(
 	vars := h.vars
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := &functions.EvaluationContext{Vars: vars, Req: r}
-		b, err := e1.Evaluate(ctx)
+		b, err := body(ctx)
 		if err != nil {
 			writeError(w, err)
 			return
@@ -94,6 +95,33 @@ func (h matchRspHTTPHandler) handleFunc(parse functions.ExpressionParser) (func(
 	}, nil
 }
 
+// parseBody returns a function computing the payload of a structured response, either by evaluating
+// the body expression or by rendering the template.
+func parseBody(rsp *cfg.MatchRsp, parse functions.ExpressionParser) (func(*functions.EvaluationContext) (interface{}, error), error) {
+	if rsp.HasTemplate() {
+		t, err := rsp.ParseTemplate()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *functions.EvaluationContext) (interface{}, error) {
+			data, err := newTemplateData(ctx)
+			if err != nil {
+				return nil, err
+			}
+			var b bytes.Buffer
+			if err := t.Execute(&b, data); err != nil {
+				return nil, err
+			}
+			return b.String(), nil
+		}, nil
+	}
+	e, err := parse(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return e.Evaluate, nil
+}
+
 func evaluateStatusCode(e functions.Expression, ctx *functions.EvaluationContext) (int, error) {
 	s, err := e.Evaluate(ctx)
 	if err != nil {
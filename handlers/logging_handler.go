@@ -2,36 +2,305 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Logger defines a general abstraction for the logging of HTTP requests.
+// Level represents the severity of a log entry, which depends on the status code of the response.
+type Level int
+
+// Supported levels.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel converts a string into a Level.
+func ParseLevel(s string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(s, n) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("'%s' is not a valid log level: select one from {'%s'}", s, strings.Join(levelNames, "', '"))
+}
+
+// LogEntry describes a request along with the response it was answered by.
+// Bodies are captured just up to the limit specified by LoggingHandler.
+type LogEntry struct {
+	Time           time.Time
+	Request        *http.Request
+	RequestBody    []byte
+	Status         int
+	Size           int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Duration       time.Duration
+	Rule           string
+}
+
+// Level returns the severity of the entry: server errors are errors, client errors are warnings,
+// requests addressed to the admin endpoints are debug information and anything else is information.
+func (e *LogEntry) Level() Level {
+	switch {
+	case e.Status >= 500:
+		return LevelError
+	case e.Status >= 400:
+		return LevelWarn
+	case e.Request != nil && strings.HasPrefix(e.Request.URL.Path, AdminPathPrefix+"/"):
+		return LevelDebug
+	default:
+		return LevelInfo
+	}
+}
+
+// Logger defines a general abstraction for the logging of HTTP requests along with their responses.
 type Logger interface {
-	log(r *http.Request)
+	Log(e *LogEntry)
 }
 
-// DefaultLogger is a basic implementation of Logger.
+// DefaultLogger is a basic implementation of Logger, writing plain text entries by the standard logger.
 type DefaultLogger struct {
 }
 
-func (l *DefaultLogger) log(r *http.Request) {
-	if r == nil {
+// Log implements the Logger interface.
+func (l *DefaultLogger) Log(e *LogEntry) {
+	if e == nil || e.Request == nil {
 		return
 	}
+	log.Printf("%s", FormatText(e))
+}
+
+// Formatter converts a log entry into a single record (new line terminated).
+type Formatter func(e *LogEntry) []byte
+
+// ParseFormatter returns the Formatter with the specified name.
+func ParseFormatter(name string) (Formatter, error) {
+	switch name {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	case "combined":
+		return FormatCombined, nil
+	default:
+		return nil, fmt.Errorf("'%s' is not a valid log format: select one from {'text', 'json', 'combined'}", name)
+	}
+}
+
+// FormatText formats a log entry as a request dump followed by the response status.
+func FormatText(e *LogEntry) []byte {
+	r := e.Request
 	var b bytes.Buffer
-	for k := range r.Header {
-		b.WriteString(fmt.Sprintf("%s: %s\n", k, r.Header.Get(k)))
+	fmt.Fprintf(&b, "\n%s %s %s\nHost: %s\n", r.Method, r.URL.String(), r.Proto, r.Host)
+	writeHeaders(&b, r.Header)
+	if len(e.RequestBody) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", e.RequestBody)
+	}
+	fmt.Fprintf(&b, "\n--> %d %s (%d bytes in %v)", e.Status, http.StatusText(e.Status), e.Size, e.Duration)
+	if e.Rule != "" {
+		fmt.Fprintf(&b, " by %s", e.Rule)
 	}
-	log.Printf("\n%s %s %s\nHost: %s\n%s\n", r.Method, r.URL.String(), r.Proto, r.Host, b.String())
+	b.WriteString("\n")
+	if len(e.ResponseBody) > 0 {
+		writeHeaders(&b, e.ResponseHeader)
+		fmt.Fprintf(&b, "\n%s\n", e.ResponseBody)
+	}
+	return b.Bytes()
 }
 
-// LoggingHandler is an http.Handler which provide logging for HTTP requests by using the specified Logger.
+func writeHeaders(b *bytes.Buffer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s: %s\n", k, strings.Join(h[k], ", "))
+	}
+}
+
+type jsonEntry struct {
+	Time            string              `json:"time"`
+	Level           string              `json:"level"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	Proto           string              `json:"proto"`
+	Host            string              `json:"host"`
+	RemoteAddr      string              `json:"remote_addr"`
+	Status          int                 `json:"status"`
+	Size            int                 `json:"size"`
+	DurationMs      float64             `json:"duration_ms"`
+	Rule            string              `json:"rule,omitempty"`
+	RequestHeaders  map[string][]string `json:"request_headers,omitempty"`
+	RequestBody     string              `json:"request_body,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	ResponseBody    string              `json:"response_body,omitempty"`
+}
+
+// FormatJSON formats a log entry as a single line JSON object.
+func FormatJSON(e *LogEntry) []byte {
+	r := e.Request
+	j := jsonEntry{
+		Time:            e.Time.Format(time.RFC3339Nano),
+		Level:           e.Level().String(),
+		Method:          r.Method,
+		URL:             r.URL.String(),
+		Proto:           r.Proto,
+		Host:            r.Host,
+		RemoteAddr:      r.RemoteAddr,
+		Status:          e.Status,
+		Size:            e.Size,
+		DurationMs:      float64(e.Duration) / float64(time.Millisecond),
+		Rule:            e.Rule,
+		RequestHeaders:  r.Header,
+		RequestBody:     string(e.RequestBody),
+		ResponseHeaders: e.ResponseHeader,
+		ResponseBody:    string(e.ResponseBody),
+	}
+	b, _ := json.Marshal(&j)
+	return append(b, '\n')
+}
+
+// FormatCombined formats a log entry by the Apache combined log format.
+func FormatCombined(e *LogEntry) []byte {
+	r := e.Request
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if host == "" {
+		host = "-"
+	}
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	size := "-"
+	if e.Size > 0 {
+		size = fmt.Sprintf("%d", e.Size)
+	}
+	return []byte(fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %q %q\n",
+		host, user, e.Time.Format("02/Jan/2006:15:04:05 -0700"), r.Method, r.URL.RequestURI(), r.Proto,
+		e.Status, size, headerOrDash(r.Referer()), headerOrDash(r.UserAgent())))
+}
+
+func headerOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// StreamLogger is a Logger writing the entries of (at least) the specified Level to Out, by using Format.
+// The values of the headers listed by Redact are replaced, so that secrets are not disclosed.
+type StreamLogger struct {
+	Out    io.Writer
+	Format Formatter
+	Level  Level
+	Redact []string
+	lock   sync.Mutex
+}
+
+// Log implements the Logger interface.
+func (l *StreamLogger) Log(e *LogEntry) {
+	if e == nil || e.Request == nil || e.Level() < l.Level {
+		return
+	}
+	if len(l.Redact) > 0 {
+		c := *e
+		r := *e.Request
+		r.Header = redactHeaders(r.Header, l.Redact)
+		c.Request = &r
+		c.ResponseHeader = redactHeaders(e.ResponseHeader, l.Redact)
+		e = &c
+	}
+	b := l.Format(e)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.Out.Write(b)
+}
+
+func redactHeaders(h http.Header, names []string) http.Header {
+	if h == nil {
+		return nil
+	}
+	r := make(http.Header, len(h))
+	for k, v := range h {
+		r[k] = v
+	}
+	for _, n := range names {
+		k := http.CanonicalHeaderKey(strings.TrimSpace(n))
+		if _, ok := r[k]; ok {
+			r[k] = []string{"[REDACTED]"}
+		}
+	}
+	return r
+}
+
+type logEntryKey struct{}
+
+// setMatchedRule records the rule a request matched into its log entry, if any.
+func setMatchedRule(r *http.Request, rule string) {
+	if e, ok := r.Context().Value(logEntryKey{}).(*LogEntry); ok {
+		e.Rule = rule
+	}
+}
+
+// LoggingHandler is an http.Handler which provide logging for HTTP requests, along with their responses,
+// by using the specified Logger. Request and response bodies are captured up to BodyLimit bytes (0 disables capturing).
 type LoggingHandler struct {
-	Logger Logger
+	Logger    Logger
+	Handler   http.Handler
+	BodyLimit int
 }
 
 func (h *LoggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.Logger.log(r)
+	e := &LogEntry{Time: time.Now()}
+	if h.BodyLimit > 0 && r.Body != nil {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(h.BodyLimit)))
+		if err == nil {
+			e.RequestBody = b
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), logEntryKey{}, e))
+	e.Request = r
+	rw := newRecordingWriter(w, h.BodyLimit)
+	h.Handler.ServeHTTP(rw, r)
+	e.Duration = time.Since(e.Time)
+	e.Status = rw.status
+	if e.Status == 0 {
+		e.Status = http.StatusOK
+	}
+	e.Size = rw.size
+	e.ResponseHeader = w.Header()
+	e.ResponseBody = rw.body
+	h.Logger.Log(e)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
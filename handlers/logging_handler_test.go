@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggingHandlerJSON(t *testing.T) {
+	var out bytes.Buffer
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setMatchedRule(r, "rule 'echo'")
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	})
+	h := LoggingHandler{
+		Logger:    &StreamLogger{Out: &out, Format: FormatJSON, Redact: []string{"authorization", "Set-Cookie"}},
+		Handler:   next,
+		BodyLimit: 4,
+	}
+	r := httptest.NewRequest("POST", "/items", strings.NewReader("hello"))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if s := w.Body.String(); s != "hello" {
+		t.Errorf("expected the whole body to be echoed; got '%s' instead", s)
+	}
+	var e jsonEntry
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatalf("could not decode the log entry '%s': %v", out.String(), err)
+	}
+	if e.Status != http.StatusCreated || e.Size != 5 || e.Level != "info" {
+		t.Errorf("unexpected status/size/level: %d/%d/%s", e.Status, e.Size, e.Level)
+	}
+	if e.Rule != "rule 'echo'" {
+		t.Errorf("expected rule 'echo' to be logged; got '%s' instead", e.Rule)
+	}
+	if e.RequestBody != "hell" || e.ResponseBody != "hell" {
+		t.Errorf("expected bodies to be truncated; got '%s' and '%s' instead", e.RequestBody, e.ResponseBody)
+	}
+	if v := e.RequestHeaders["Authorization"]; len(v) != 1 || v[0] != "[REDACTED]" {
+		t.Errorf("expected the authorization header to be redacted; got '%v' instead", v)
+	}
+	if v := e.ResponseHeaders["Set-Cookie"]; len(v) != 1 || v[0] != "[REDACTED]" {
+		t.Errorf("expected the set-cookie header to be redacted; got '%v' instead", v)
+	}
+	if v := w.Header().Get("Set-Cookie"); v != "session=secret" {
+		t.Errorf("expected the response not to be affected by redaction; got '%s' instead", v)
+	}
+}
+
+func TestLoggingHandlerLevel(t *testing.T) {
+	var out bytes.Buffer
+	h := LoggingHandler{
+		Logger:  &StreamLogger{Out: &out, Format: FormatCombined, Level: LevelWarn},
+		Handler: http.HandlerFunc(http.NotFound),
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing?a=1", nil))
+	h.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/found", nil))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected just one entry to be logged; got %d instead", len(lines))
+	}
+	if !strings.Contains(lines[0], `"GET /missing?a=1 HTTP/1.1" 404 19 "-" "-"`) {
+		t.Errorf("unexpected combined log entry '%s'", lines[0])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if l, err := ParseLevel("WARN"); err != nil || l != LevelWarn {
+		t.Errorf("expected level 'warn'; got '%v' (%v) instead", l, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("expected an error for an unknown level")
+	}
+}
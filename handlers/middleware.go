@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+// Middleware wraps an http.Handler, so that it can act before and after it (e.g. by wrapping the http.ResponseWriter)
+// or answer requests in its place.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h by the specified middleware, the first one being the outermost.
+func Chain(h http.Handler, m ...Middleware) http.Handler {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
+
+// NewPipeline wraps h by the middleware with the specified names (the first one being the outermost),
+// as they are found in registry.
+func NewPipeline(h http.Handler, names []string, registry map[string]Middleware) (http.Handler, error) {
+	m := make([]Middleware, 0, len(names))
+	for _, name := range names {
+		e, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a valid middleware", name)
+		}
+		m = append(m, e)
+	}
+	return Chain(h, m...), nil
+}
+
+// Logging is a Middleware logging requests along with their responses by the specified Logger.
+// Request and response bodies are captured up to bodyLimit bytes.
+func Logging(logger Logger, bodyLimit int) Middleware {
+	return func(h http.Handler) http.Handler {
+		return &LoggingHandler{Logger: logger, Handler: h, BodyLimit: bodyLimit}
+	}
+}
+
+// Cors is a Middleware providing support for Cross-Origin Resource Sharing by the specified policy,
+// whether not nil.
+func Cors(config *cfg.CorsConfig) (Middleware, error) {
+	if config == nil {
+		return func(h http.Handler) http.Handler { return h }, nil
+	}
+	// the policy is checked once, so that wrapping never fails
+	if _, err := NewCorsHandler(nil, config); err != nil {
+		return nil, err
+	}
+	return func(h http.Handler) http.Handler {
+		c, _ := NewCorsHandler(h, config)
+		return c
+	}, nil
+}
+
+// Recording is a Middleware answering requests by the responses recorded by store, if any.
+func Recording(store StoreHandler) Middleware {
+	return func(h http.Handler) http.Handler {
+		if store == nil {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !store.ServeHTTP(w, r) {
+				h.ServeHTTP(w, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func tagMiddleware(tag string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", tag)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestNewPipeline(t *testing.T) {
+	registry := map[string]Middleware{"a": tagMiddleware("a"), "b": tagMiddleware("b")}
+	h, err := NewPipeline(http.NotFoundHandler(), []string{"b", "a"}, registry)
+	if err != nil {
+		t.Errorf("NewPipeline raised an error: %v", err)
+		return
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if o := w.Header()["X-Order"]; len(o) != 2 || o[0] != "b" || o[1] != "a" {
+		t.Errorf("expected middleware to be applied in order 'b', 'a'; got %v instead", o)
+	}
+	if _, err := NewPipeline(http.NotFoundHandler(), []string{"c"}, registry); err == nil {
+		t.Errorf("expected an error for an unknown middleware")
+	}
+}
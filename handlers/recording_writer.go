@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// recordingWriter is an http.ResponseWriter keeping track of the status code and the size of a response,
+// along with its leading bytes (up to limit).
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	limit  int
+	body   []byte
+}
+
+func newRecordingWriter(w http.ResponseWriter, limit int) *recordingWriter {
+	return &recordingWriter{ResponseWriter: w, limit: limit}
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if n := w.limit - len(w.body); n > 0 {
+		if n > len(b) {
+			n = len(b)
+		}
+		w.body = append(w.body, b[:n]...)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush supports streaming responses whether the underlying writer does.
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports protocol upgrades whether the underlying writer does.
+func (w *recordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the underlying response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
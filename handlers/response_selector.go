@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/naighes/imposter/cfg"
+	"github.com/naighes/imposter/functions"
+)
+
+// responseSelector picks the response a matching request is handled by, keeping track
+// of the number of matches a rule received so far.
+type responseSelector struct {
+	mode     cfg.ResponseMode
+	stick    bool
+	handlers []http.Handler
+	weights  []int
+	count    int
+	lock     sync.Mutex
+}
+
+func newResponseSelector(def *cfg.MatchDef, vars map[string]interface{}) (*responseSelector, error) {
+	if def.Responses == nil {
+		f, err := HandleFunc(def.Response, vars)
+		if err != nil {
+			return nil, err
+		}
+		return &responseSelector{mode: cfg.ResponseModeSequence, stick: true, handlers: []http.Handler{http.HandlerFunc(f)}, weights: []int{1}}, nil
+	}
+	mode, err := cfg.ParseResponseMode(def.ResponseMode)
+	if err != nil {
+		return nil, err
+	}
+	s := &responseSelector{mode: mode, stick: def.StickOnLast}
+	for _, e := range def.Responses {
+		f, err := HandleFunc(e.Response, vars)
+		if err != nil {
+			return nil, err
+		}
+		s.handlers = append(s.handlers, http.HandlerFunc(f))
+		s.weights = append(s.weights, e.Weight)
+	}
+	return s, nil
+}
+
+// next returns the handler of the current match; nil is returned whether a sequence is exhausted.
+func (s *responseSelector) next() http.Handler {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.handlers) == 0 {
+		return nil
+	}
+	i := s.count
+	s.count++
+	switch s.mode {
+	case cfg.ResponseModeCycle:
+		return s.handlers[i%len(s.handlers)]
+	case cfg.ResponseModeRandom:
+		n, _ := functions.RandomInt(0, len(s.handlers)-1)
+		return s.handlers[n]
+	case cfg.ResponseModeWeighted:
+		return s.weighted()
+	default:
+		if i < len(s.handlers) {
+			return s.handlers[i]
+		}
+		if s.stick {
+			return s.handlers[len(s.handlers)-1]
+		}
+		return nil
+	}
+}
+
+// exhausted reports whether the next match would not be handled, since a sequence is over.
+func (s *responseSelector) exhausted() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.handlers) == 0 {
+		return true
+	}
+	switch s.mode {
+	case cfg.ResponseModeCycle, cfg.ResponseModeRandom:
+		return false
+	case cfg.ResponseModeWeighted:
+		for _, w := range s.weights {
+			if w > 0 {
+				return false
+			}
+		}
+		return true
+	default:
+		return s.count >= len(s.handlers) && !s.stick
+	}
+}
+
+func (s *responseSelector) weighted() http.Handler {
+	total := 0
+	for _, w := range s.weights {
+		total += w
+	}
+	if total <= 0 {
+		return nil
+	}
+	n, _ := functions.RandomInt(0, total-1)
+	for i, w := range s.weights {
+		if n < w {
+			return s.handlers[i]
+		}
+		n -= w
+	}
+	return nil
+}
+
+func (s *responseSelector) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.count = 0
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -13,77 +19,235 @@ import (
 
 // RouterHandler type processes all incoming HTTP requests and look up for any matching rule expression.
 // Then it applies the specified response object in case of a successful match.
+// Requests addressed to a virtual host are matched against its own rules in place of the top-level ones.
+// In Debug mode, unmatched requests are answered (and logged) with an Explanation of the mismatch.
 type RouterHandler struct {
-	routes       []*route
-	vars         map[string]interface{}
-	storeHandler StoreHandler
+	Debug    bool
+	routes   []*route
+	fallback http.Handler
+	vars     map[string]interface{}
+	hosts    []*virtualHost
+}
+
+// virtualHost holds the rules serving the requests addressed to a cfg.VirtualHost.
+type virtualHost struct {
+	label    string
+	config   *cfg.VirtualHost
+	patterns []*regexp.Regexp
+	router   *RouterHandler
+}
+
+// match determines whether a request is addressed to the virtual host, by its Host header or the server name
+// requested by TLS clients.
+func (vh *virtualHost) match(r *http.Request) bool {
+	names := []string{requestHostName(r)}
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		names = append(names, r.TLS.ServerName)
+	}
+	for _, n := range names {
+		if vh.config.MatchHost(n) {
+			return true
+		}
+		for _, p := range vh.patterns {
+			if p.MatchString(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func requestHostName(r *http.Request) string {
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		return h
+	}
+	return r.Host
+}
+
+// dispatch returns the router holding the rules the request is matched against.
+func (router *RouterHandler) dispatch(r *http.Request) *RouterHandler {
+	for _, vh := range router.hosts {
+		if vh.match(r) {
+			return vh.router
+		}
+	}
+	return router
+}
+
+// paramsKey is the context key the named groups captured by a matching rule expression are stored under.
+type paramsKey struct{}
+
+// requestParams returns the named groups captured by the rule expression the request matched.
+func requestParams(r *http.Request) map[string]string {
+	if p, ok := r.Context().Value(paramsKey{}).(map[string]string); ok {
+		return p
+	}
+	return make(map[string]string)
 }
 
 type route struct {
+	scope      string
+	index      int
+	name       string
+	source     string
+	priority   int
 	expression functions.Expression
 	latency    time.Duration
-	handler    http.Handler
+	responses  *responseSelector
+}
+
+// label identifies a route within logs and error messages, by name whether available.
+func (route *route) label() string {
+	var l string
+	if route.name != "" {
+		l = fmt.Sprintf("rule '%s'", route.name)
+	} else {
+		l = fmt.Sprintf("rule #%d", route.index)
+	}
+	if route.scope != "" {
+		return fmt.Sprintf("%s of %s", l, route.scope)
+	}
+	return l
 }
 
 func (router *RouterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if router.storeHandler != nil && router.storeHandler.ServeHTTP(w, r) {
+	if r != nil && strings.HasPrefix(r.URL.Path, AdminPathPrefix+"/") {
+		router.serveAdmin(w, r)
+		return
+	}
+	rules := router.dispatch(r)
+	if rules.serveRoutes(w, r) {
+		return
+	}
+	fallback := rules.fallback
+	if fallback == nil {
+		fallback = router.fallback
+	}
+	if router.Debug && r != nil {
+		x := router.Explain(r).String()
+		log.Printf("\n%s", x)
+		if fallback == nil {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, x)
+			return
+		}
+	}
+	if fallback != nil {
+		fallback.ServeHTTP(w, r)
 		return
 	}
+	http.NotFound(w, r)
+}
+
+// serveRoutes answers the request by the first matching rule, if any: false is returned whether no rule matched.
+func (router *RouterHandler) serveRoutes(w http.ResponseWriter, r *http.Request) bool {
 	for _, route := range router.routes {
 		// TODO: X-Forwarded-Host?
-		ctx := &functions.EvaluationContext{Vars: router.vars, Req: r}
+		ctx := &functions.EvaluationContext{Vars: router.vars, Req: r, Params: make(map[string]string)}
 		a, err := route.expression.Evaluate(ctx)
 		if err != nil {
-			writeError(w, err)
-			return
+			writeError(w, fmt.Errorf("%s: %v", route.label(), err))
+			return true
 		}
 		b, ok := a.(bool)
 		if !ok {
-			writeError(w, fmt.Errorf("rule_expression requires a 'bool' expression: found '%v' instead", reflect.TypeOf(a)))
-			return
+			writeError(w, fmt.Errorf("%s: rule_expression requires a 'bool' expression: found '%v' instead", route.label(), reflect.TypeOf(a)))
+			return true
 		}
 		if b {
+			h := route.responses.next()
+			if h == nil {
+				continue
+			}
 			if route.latency > 0 {
 				time.Sleep(route.latency * time.Millisecond)
 			}
-			route.handler.ServeHTTP(w, r)
-			return
+			setMatchedRule(r, route.label())
+			if len(ctx.Params) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, ctx.Params))
+			}
+			h.ServeHTTP(w, r)
+			return true
 		}
 	}
-	// TODO: not sure about just returning not found...
-	http.NotFound(w, r)
+	return false
 }
 
-func (router *RouterHandler) add(expression functions.Expression, latency time.Duration, h func(http.ResponseWriter, *http.Request)) {
-	router.routes = append(router.routes, &route{expression, latency, http.HandlerFunc(h)})
+func (router *RouterHandler) add(route *route) {
+	router.routes = append(router.routes, route)
 }
 
 // NewRouterHandler builds a new RouterHandler.
-func NewRouterHandler(config *cfg.Config, storeHandler StoreHandler) (*RouterHandler, error) {
-	defs := config.Defs
+func NewRouterHandler(config *cfg.Config) (*RouterHandler, error) {
 	var vars map[string]interface{}
 	if config.Vars == nil {
 		vars = make(map[string]interface{})
 	} else {
 		vars = config.Vars
 	}
+	r, err := newRouter(config.Defs, vars, "")
+	if err != nil {
+		return nil, err
+	}
+	if r.fallback, err = newFallbackHandler(config, vars); err != nil {
+		return nil, fmt.Errorf("unmatched requests: %v", err)
+	}
+	for _, e := range config.VirtualHosts {
+		vh := &virtualHost{label: e.Label(), config: e}
+		for _, p := range e.HostPatterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", vh.label, err)
+			}
+			vh.patterns = append(vh.patterns, re)
+		}
+		v := e.ResolveVars(vars)
+		if vh.router, err = newRouter(e.Defs, v, vh.label); err != nil {
+			return nil, err
+		}
+		if e.DefaultResponse != nil {
+			f, err := HandleFunc(e.DefaultResponse, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: unmatched requests: %v", vh.label, err)
+			}
+			vh.router.fallback = http.HandlerFunc(f)
+		}
+		r.hosts = append(r.hosts, vh)
+	}
+	return r, nil
+}
+
+// newRouter builds a router testing the specified rules, within scope (a virtual host, if any).
+func newRouter(defs []*cfg.MatchDef, vars map[string]interface{}, scope string) (*RouterHandler, error) {
 	r := RouterHandler{}
 	r.vars = vars
-	r.storeHandler = storeHandler
-	for _, def := range defs {
+	names := make(map[string]bool)
+	for i, def := range defs {
+		rt := &route{scope: scope, index: i, name: def.Name, source: def.Source().String(), priority: def.Priority, latency: def.Latency}
+		if def.Name != "" {
+			if names[def.Name] {
+				return nil, fmt.Errorf("%s: rule name is not unique", rt.label())
+			}
+			names[def.Name] = true
+		}
 		rule, err := functions.ParseExpression(def.RuleExpression)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %v", rt.label(), err)
 		}
-		f, err := HandleFunc(def.Response, vars)
-		if err != nil {
-			return nil, err
+		rt.expression = rule
+		if rt.responses, err = newResponseSelector(def, vars); err != nil {
+			return nil, fmt.Errorf("%s: %v", rt.label(), err)
 		}
 		if def.Latency < 0 {
-			return nil, fmt.Errorf("latency requires a value greater than zero")
+			return nil, fmt.Errorf("%s: latency requires a value greater than zero", rt.label())
 		}
-		r.add(rule, def.Latency, f)
+		r.add(rt)
 	}
+	// rules with higher priority are tested first, while rules with the same priority are tested in order
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return r.routes[i].priority > r.routes[j].priority
+	})
 	return &r, nil
 }
 
@@ -95,8 +259,36 @@ func writeError(w http.ResponseWriter, err error) {
 
 // HandleFunc type determines the proper HTTPHandler the current HTTP request should be managed by.
 func HandleFunc(o interface{}, vars map[string]interface{}) (func(http.ResponseWriter, *http.Request), error) {
+	script, err := cfg.DecodeScriptRsp(o)
+	if err != nil {
+		return nil, err
+	}
+	if script != nil {
+		return scriptHTTPHandler{content: script, vars: vars}.handleFunc()
+	}
+	grpc, err := cfg.DecodeGRPCRsp(o)
+	if err != nil {
+		return nil, err
+	}
+	if grpc != nil {
+		return grpcHTTPHandler{content: grpc}.handleFunc()
+	}
+	ws, err := cfg.DecodeWebSocketRsp(o)
+	if err != nil {
+		return nil, err
+	}
+	if ws != nil {
+		return webSocketHTTPHandler{content: ws, vars: vars}.handleFunc(functions.ParseExpression)
+	}
+	sse, err := cfg.DecodeSSERsp(o)
+	if err != nil {
+		return nil, err
+	}
+	if sse != nil {
+		return sseHTTPHandler{content: sse, vars: vars}.handleFunc(functions.ParseExpression)
+	}
 	var rsp cfg.MatchRsp
-	err := mapstructure.Decode(o, &rsp)
+	err = mapstructure.Decode(o, &rsp)
 	if err == nil {
 		return matchRspHTTPHandler{content: &rsp, vars: vars}.handleFunc(functions.ParseExpression)
 	}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/naighes/imposter/cfg"
@@ -12,7 +14,7 @@ import (
 func TestEmptyRuleSet(t *testing.T) {
 	config := cfg.Config{}
 	r := httptest.NewRecorder()
-	routes, err := NewRouterHandler(&config, nil)
+	routes, err := NewRouterHandler(&config)
 	if err != nil {
 		t.Errorf("cannot create a new instance of NewRouterHandler")
 	}
@@ -32,7 +34,7 @@ func TestMatchingRule(t *testing.T) {
 	defs := []*cfg.MatchDef{&def}
 	config := cfg.Config{Defs: defs}
 	r := httptest.NewRecorder()
-	routes, err := NewRouterHandler(&config, nil)
+	routes, err := NewRouterHandler(&config)
 	if err != nil {
 		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
 	}
@@ -56,7 +58,7 @@ func TestNonMatchingRule(t *testing.T) {
 	defs := []*cfg.MatchDef{&def}
 	config := cfg.Config{Defs: defs}
 	r := httptest.NewRecorder()
-	routes, err := NewRouterHandler(&config, nil)
+	routes, err := NewRouterHandler(&config)
 	if err != nil {
 		t.Errorf("cannot create a new instance of NewRouterHandler")
 	}
@@ -67,3 +69,275 @@ func TestNonMatchingRule(t *testing.T) {
 		t.Errorf("expected status code %d; got %d: %s", expected, r.Code, r.Body.String())
 	}
 }
+
+func TestTemplateWithPathParams(t *testing.T) {
+	const expected = `{"id":"123","name":"imposter","query":"full","env":"test"}`
+	rsp := cfg.MatchRsp{Template: `{"id":{{json .Params.id}},"name":{{json .JSON.name}},"query":"{{.Query.view}}","env":"{{.Vars.env}}"}`}
+	def := cfg.MatchDef{RuleExpression: `${regex_match(request_url_path(), "^/items/(?P<id>[0-9]+)$")}`, Response: &rsp}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&def}, Vars: map[string]interface{}{"env": "test"}}
+	r := httptest.NewRecorder()
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	routes.ServeHTTP(r, httptest.NewRequest("POST", "/items/123?view=full", strings.NewReader(`{"name": "imposter"}`)))
+	if b := r.Body.String(); b != expected {
+		t.Errorf("expected body '%s'; got '%s' instead", expected, b)
+	}
+}
+
+func TestResponseSequence(t *testing.T) {
+	def := cfg.MatchDef{RuleExpression: `${true}`, StickOnLast: true, Responses: []*cfg.WeightedRsp{
+		{Response: &cfg.MatchRsp{StatusCode: "${503}"}},
+		{Response: &cfg.MatchRsp{StatusCode: "${503}"}},
+		{Response: &cfg.MatchRsp{StatusCode: "${200}"}},
+	}}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&def}}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	serve := func(method, path string) int {
+		r := httptest.NewRecorder()
+		routes.ServeHTTP(r, httptest.NewRequest(method, path, nil))
+		return r.Code
+	}
+	for i, expected := range []int{503, 503, 200, 200} {
+		if c := serve("GET", "/"); c != expected {
+			t.Errorf("expected status code %d at match #%d; got %d", expected, i, c)
+			return
+		}
+	}
+	if c := serve("DELETE", AdminPathPrefix+"/responses/0"); c != 204 {
+		t.Errorf("expected status code %d; got %d", 204, c)
+		return
+	}
+	if c := serve("GET", "/"); c != 503 {
+		t.Errorf("expected status code %d after reset; got %d", 503, c)
+	}
+}
+
+func TestExhaustedResponseSequence(t *testing.T) {
+	first := cfg.MatchDef{RuleExpression: `${true}`, Responses: []*cfg.WeightedRsp{
+		{Response: &cfg.MatchRsp{StatusCode: "${503}"}},
+	}}
+	second := cfg.MatchDef{RuleExpression: `${true}`, Response: &cfg.MatchRsp{StatusCode: "${200}"}}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&first, &second}}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	for i, expected := range []int{503, 200, 200} {
+		r := httptest.NewRecorder()
+		routes.ServeHTTP(r, httptest.NewRequest("GET", "/", nil))
+		if r.Code != expected {
+			t.Errorf("expected status code %d at match #%d; got %d", expected, i, r.Code)
+			return
+		}
+	}
+	x := routes.Explain(httptest.NewRequest("GET", "/", nil))
+	if x.Match != "rule #1" || !x.Rules[0].Matched || !x.Rules[0].Exhausted {
+		t.Errorf("expected the exhausted rule #0 to be skipped in favor of rule #1; got '%s'", x.Match)
+	}
+}
+
+func TestResponseCycle(t *testing.T) {
+	def := cfg.MatchDef{RuleExpression: `${true}`, ResponseMode: cfg.ResponseModeCycle, Responses: []*cfg.WeightedRsp{
+		{Response: &cfg.MatchRsp{StatusCode: "${200}"}},
+		{Response: &cfg.MatchRsp{StatusCode: "${201}"}},
+	}}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&def}}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	for i, expected := range []int{200, 201, 200, 201} {
+		r := httptest.NewRecorder()
+		routes.ServeHTTP(r, httptest.NewRequest("GET", "/", nil))
+		if r.Code != expected {
+			t.Errorf("expected status code %d at match #%d; got %d", expected, i, r.Code)
+			return
+		}
+	}
+}
+
+func TestRulePriority(t *testing.T) {
+	const expected = 201
+	low := cfg.MatchDef{Name: "low", RuleExpression: `${true}`, Response: &cfg.MatchRsp{StatusCode: "${200}"}}
+	high := cfg.MatchDef{Name: "high", Priority: 10, RuleExpression: `${true}`, Response: &cfg.MatchRsp{StatusCode: "${201}"}}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&low, &high}}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	r := httptest.NewRecorder()
+	routes.ServeHTTP(r, httptest.NewRequest("GET", "/", nil))
+	if r.Code != expected {
+		t.Errorf("expected status code %d; got %d", expected, r.Code)
+	}
+}
+
+func TestDuplicateRuleName(t *testing.T) {
+	first := cfg.MatchDef{Name: "users", RuleExpression: `${true}`, Response: &cfg.MatchRsp{}}
+	second := cfg.MatchDef{Name: "users", RuleExpression: `${true}`, Response: &cfg.MatchRsp{}}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&first, &second}}
+	if _, err := NewRouterHandler(&config); err == nil || !strings.Contains(err.Error(), "rule 'users'") {
+		t.Errorf("expected an error mentioning rule 'users'; got '%v' instead", err)
+	}
+}
+
+func TestExplainMismatch(t *testing.T) {
+	def := cfg.MatchDef{Name: "get-user", RuleExpression: `${
+		and(
+			eq(request_http_method(), "GET"),
+			regex_match(request_url_path(), "^/users/[0-9]+$")
+		)
+	}`, Response: &cfg.MatchRsp{}}
+	other := cfg.MatchDef{RuleExpression: `${eq(request_http_method(), "DELETE")}`, Response: &cfg.MatchRsp{}}
+	config := cfg.Config{Defs: []*cfg.MatchDef{&other, &def}}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	routes.Debug = true
+	r := httptest.NewRecorder()
+	routes.ServeHTTP(r, httptest.NewRequest("GET", "/users/abc", nil))
+	if r.Code != 404 {
+		t.Errorf("expected status code %d; got %d", 404, r.Code)
+		return
+	}
+	x := routes.Explain(httptest.NewRequest("GET", "/users/abc", nil))
+	if x.Match != "" || len(x.Rules) != 2 {
+		t.Errorf("expected no matching rules out of 2; got '%s' out of %d", x.Match, len(x.Rules))
+		return
+	}
+	e := x.Rules[1]
+	if l := len(e.Failures); l != 1 || e.Failures[0] != `regex_match(request_url_path(), "^/users/[0-9]+$")` {
+		t.Errorf("expected the regex_match sub-expression to be the only failure; got %v", e.Failures)
+		return
+	}
+	if len(x.Closest) == 0 || !strings.HasPrefix(x.Closest[0], "rule 'get-user'") {
+		t.Errorf("expected rule 'get-user' to be the closest match; got %v", x.Closest)
+	}
+	if !strings.Contains(r.Body.String(), "closest matches:") {
+		t.Errorf("expected the explanation to be returned; got '%s'", r.Body.String())
+	}
+}
+
+func TestDefaultResponse(t *testing.T) {
+	const expected = 418
+	config := cfg.Config{DefaultResponse: &cfg.MatchRsp{StatusCode: "${418}"}}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	r := httptest.NewRecorder()
+	routes.ServeHTTP(r, httptest.NewRequest("GET", "/unknown", nil))
+	if r.Code != expected {
+		t.Errorf("expected status code %d; got %d", expected, r.Code)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	const expected = 501
+	config := cfg.Config{Strict: true}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	r := httptest.NewRecorder()
+	routes.ServeHTTP(r, httptest.NewRequest("GET", "/unknown?id=1", nil))
+	if r.Code != expected {
+		t.Errorf("expected status code %d; got %d", expected, r.Code)
+		return
+	}
+	var rsp struct {
+		Request struct {
+			Path  string              `json:"path"`
+			Query map[string][]string `json:"query"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(r.Body.Bytes(), &rsp); err != nil {
+		t.Errorf("expected a JSON body: %v", err)
+		return
+	}
+	if rsp.Request.Path != "/unknown" || rsp.Request.Query["id"][0] != "1" {
+		t.Errorf("unexpected request details '%s'", r.Body.String())
+	}
+}
+
+func TestUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(202)
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+	config := cfg.Config{Upstream: upstream.URL}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	r := httptest.NewRecorder()
+	routes.ServeHTTP(r, httptest.NewRequest("GET", "/proxied", nil))
+	if r.Code != 202 || r.Body.String() != "/proxied" {
+		t.Errorf("expected the request to be proxied; got %d '%s'", r.Code, r.Body.String())
+	}
+}
+
+func TestVirtualHosts(t *testing.T) {
+	config := cfg.Config{
+		Defs: []*cfg.MatchDef{
+			{RuleExpression: `${eq(request_url_path(), "/who")}`, Response: &cfg.MatchRsp{Body: "default", StatusCode: "${200}"}},
+		},
+		Vars: map[string]interface{}{"service": "none"},
+		VirtualHosts: []*cfg.VirtualHost{
+			{
+				Hosts: []string{"users.local", "*.users.local"},
+				Defs: []*cfg.MatchDef{
+					{RuleExpression: `${eq(request_url_path(), "/who")}`, Response: &cfg.MatchRsp{Body: `${var("service")}`, StatusCode: "${200}"}},
+				},
+				Vars: map[string]interface{}{"service": "users"},
+			},
+			{
+				HostPatterns:    []string{`^orders-[0-9]+$`},
+				DefaultResponse: &cfg.MatchRsp{StatusCode: "${418}"},
+			},
+		},
+		Strict: true,
+	}
+	routes, err := NewRouterHandler(&config)
+	if err != nil {
+		t.Errorf("cannot create a new instance of NewRouterHandler: %v", err)
+		return
+	}
+	tests := []struct {
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{"users.local:8080", "/who", 200, "users"},
+		{"api.users.local", "/who", 200, "users"},
+		{"users.local", "/unknown", 501, ""},
+		{"orders-1", "/who", 418, ""},
+		{"example.com", "/who", 200, "default"},
+	}
+	for _, e := range tests {
+		r := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", e.path, nil)
+		req.Host = e.host
+		routes.ServeHTTP(r, req)
+		if r.Code != e.status || (e.body != "" && r.Body.String() != e.body) {
+			t.Errorf("%s%s: expected %d '%s'; got %d '%s' instead", e.host, e.path, e.status, e.body, r.Code, r.Body.String())
+		}
+	}
+}
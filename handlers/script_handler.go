@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/naighes/imposter/cfg"
+)
+
+// scriptState is shared by all scripts and it survives across requests.
+// Scripts are executed one at a time, so that they can safely update it: each one runs on a copy, which
+// replaces the state only when the script succeeds.
+var scriptState = struct {
+	values map[string]interface{}
+	lock   sync.Mutex
+}{values: make(map[string]interface{})}
+
+type scriptHTTPHandler struct {
+	content *cfg.ScriptRsp
+	vars    map[string]interface{}
+}
+
+func (h scriptHTTPHandler) handleFunc() (func(http.ResponseWriter, *http.Request), error) {
+	program, err := h.content.Compile()
+	if err != nil {
+		return nil, err
+	}
+	timeout := h.content.Timeout * time.Millisecond
+	if timeout == 0 {
+		timeout = cfg.DefaultScriptTimeout
+	}
+	vars := h.vars
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := scriptRequest(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		rsp, err := runScript(program, timeout, req, vars)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeScriptRsp(w, rsp)
+	}, nil
+}
+
+// scriptRequest builds the request object exposed to scripts.
+func scriptRequest(r *http.Request) (map[string]interface{}, error) {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	headers := make(map[string]interface{})
+	for k := range r.Header {
+		headers[strings.ToLower(k)] = r.Header.Get(k)
+	}
+	query := make(map[string]interface{})
+	for k := range r.URL.Query() {
+		query[k] = r.URL.Query().Get(k)
+	}
+	req := map[string]interface{}{
+		"method":  r.Method,
+		"url":     r.URL.String(),
+		"path":    r.URL.Path,
+		"query":   query,
+		"headers": headers,
+		"host":    r.Host,
+		"body":    string(body),
+		"params":  requestParams(r),
+	}
+	var j interface{}
+	if err := json.Unmarshal(body, &j); err == nil {
+		req["json"] = j
+	}
+	return req, nil
+}
+
+func runScript(program *goja.Program, timeout time.Duration, req map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	// scripts get their own copy of vars, which are shared by any request
+	vars = copyValue(vars).(map[string]interface{})
+	scriptState.lock.Lock()
+	defer scriptState.lock.Unlock()
+	state := copyValue(scriptState.values).(map[string]interface{})
+	vm := goja.New()
+	t := time.AfterFunc(timeout, func() {
+		vm.Interrupt(fmt.Errorf("script execution exceeded the timeout of %v", timeout))
+	})
+	defer t.Stop()
+	v, err := vm.RunProgram(program)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := goja.AssertFunction(v)
+	if !ok {
+		return nil, fmt.Errorf("script could not be compiled into a function")
+	}
+	a, err := f(goja.Undefined(), vm.ToValue(req), vm.ToValue(vars), vm.ToValue(state))
+	if err != nil {
+		return nil, err
+	}
+	scriptState.values = state
+	rsp, ok := a.Export().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("script is expected to return an object made of status, headers and body fields; got '%v' instead", a)
+	}
+	return rsp, nil
+}
+
+// copyValue returns a deep copy of the maps and slices making v, so that scripts do not share them.
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			r[k] = copyValue(e)
+		}
+		return r
+	case map[interface{}]interface{}:
+		r := make(map[interface{}]interface{}, len(t))
+		for k, e := range t {
+			r[k] = copyValue(e)
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(t))
+		for i, e := range t {
+			r[i] = copyValue(e)
+		}
+		return r
+	default:
+		return v
+	}
+}
+
+func writeScriptRsp(w http.ResponseWriter, rsp map[string]interface{}) {
+	statusCode := http.StatusOK
+	if s, ok := rsp["status"]; ok {
+		i, ok := s.(int64)
+		if !ok || i <= 0 {
+			writeError(w, fmt.Errorf("expected a positive integer value for status; got '%v' instead", s))
+			return
+		}
+		statusCode = int(i)
+	}
+	if headers, ok := rsp["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			w.Header().Set(k, fmt.Sprintf("%v", v))
+		}
+	}
+	var body string
+	switch b := rsp["body"].(type) {
+	case nil:
+	case string:
+		body = b
+	default:
+		c, err := json.Marshal(b)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		body = string(c)
+	}
+	w.WriteHeader(statusCode)
+	fmt.Fprint(w, body)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+func TestScriptHTTPHandlerNoErrors(t *testing.T) {
+	script := `return {status: 201, headers: {"X-Method": request.method}, body: {id: request.json.id, env: vars.env}}`
+	h := scriptHTTPHandler{content: &cfg.ScriptRsp{Script: script}, vars: map[string]interface{}{"env": "test"}}
+	f, err := h.handleFunc()
+	if err != nil {
+		t.Errorf("handleFunc raised an error: %v", err)
+		return
+	}
+	r := httptest.NewRecorder()
+	f(r, httptest.NewRequest("POST", "/items", strings.NewReader(`{"id": 42}`)))
+	if r.Code != 201 {
+		t.Errorf("expected status code %d; got %d instead", 201, r.Code)
+	}
+	if h := r.Header().Get("X-Method"); h != "POST" {
+		t.Errorf("expected header 'POST'; got '%s' instead", h)
+	}
+	if h := r.Header().Get("Content-Type"); h != "application/json" {
+		t.Errorf("expected content type 'application/json'; got '%s' instead", h)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	if s := string(b); s != `{"env":"test","id":42}` {
+		t.Errorf("unexpected body '%s'", s)
+	}
+}
+
+func TestScriptHTTPHandlerState(t *testing.T) {
+	script := `state.hits = (state.hits || 0) + 1; return {body: "" + state.hits}`
+	h := scriptHTTPHandler{content: &cfg.ScriptRsp{Script: script}}
+	f, err := h.handleFunc()
+	if err != nil {
+		t.Errorf("handleFunc raised an error: %v", err)
+		return
+	}
+	var s string
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRecorder()
+		f(r, httptest.NewRequest("GET", "/", nil))
+		b, _ := ioutil.ReadAll(r.Body)
+		s = string(b)
+	}
+	if s != "3" {
+		t.Errorf("expected '3' hits; got '%s' instead", s)
+	}
+}
+
+func TestScriptHTTPHandlerConcurrency(t *testing.T) {
+	const requests = 50
+	vars := map[string]interface{}{"env": "test"}
+	script := `state.count = (state.count || 0) + 1; vars.env = "changed"; return {body: vars.env}`
+	f, err := scriptHTTPHandler{content: &cfg.ScriptRsp{Script: script}, vars: vars}.handleFunc()
+	if err != nil {
+		t.Errorf("handleFunc raised an error: %v", err)
+		return
+	}
+	failing, err := scriptHTTPHandler{content: &cfg.ScriptRsp{Script: `state.count = -1; throw "failure"`}}.handleFunc()
+	if err != nil {
+		t.Errorf("handleFunc raised an error: %v", err)
+		return
+	}
+	scriptState.lock.Lock()
+	scriptState.values = make(map[string]interface{})
+	scriptState.lock.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			f(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		go func() {
+			defer wg.Done()
+			// vars are read by rules, e.g. by var(), while scripts run
+			if vars["env"] != "test" {
+				t.Errorf("expected vars not to be changed by scripts; got '%v' instead", vars["env"])
+			}
+			failing(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+	wg.Wait()
+	scriptState.lock.Lock()
+	defer scriptState.lock.Unlock()
+	if c := scriptState.values["count"]; c != int64(requests) {
+		t.Errorf("expected a count of %d; got '%v' instead", requests, c)
+	}
+}
+
+func TestScriptHTTPHandlerTimeout(t *testing.T) {
+	h := scriptHTTPHandler{content: &cfg.ScriptRsp{Script: `while (true) {}`, Timeout: 50}}
+	f, err := h.handleFunc()
+	if err != nil {
+		t.Errorf("handleFunc raised an error: %v", err)
+		return
+	}
+	r := httptest.NewRecorder()
+	f(r, httptest.NewRequest("GET", "/", nil))
+	if r.Code != 500 {
+		t.Errorf("expected status code %d; got %d instead", 500, r.Code)
+	}
+}
+
+func TestScriptHTTPHandlerSyntaxError(t *testing.T) {
+	h := scriptHTTPHandler{content: &cfg.ScriptRsp{Script: `return {`}}
+	if _, err := h.handleFunc(); err == nil {
+		t.Errorf("expected a compilation error")
+	}
+}
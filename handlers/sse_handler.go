@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/naighes/imposter/cfg"
+	"github.com/naighes/imposter/functions"
+)
+
+type sseEvent struct {
+	id    functions.Expression
+	event functions.Expression
+	data  functions.Expression
+	retry int
+	delay time.Duration
+}
+
+type sseHTTPHandler struct {
+	content *cfg.SSERsp
+	vars    map[string]interface{}
+}
+
+func (h sseHTTPHandler) handleFunc(parse functions.ExpressionParser) (func(http.ResponseWriter, *http.Request), error) {
+	var events []*sseEvent
+	for _, e := range h.content.Events {
+		if e == nil {
+			continue
+		}
+		event := &sseEvent{retry: e.Retry, delay: e.Delay * time.Millisecond}
+		var err error
+		if event.id, err = parseOptional(e.ID, parse); err != nil {
+			return nil, err
+		}
+		if event.event, err = parseOptional(e.Event, parse); err != nil {
+			return nil, err
+		}
+		if event.data, err = parseOptional(e.Data, parse); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	repeat := h.content.Repeat
+	vars := h.vars
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, fmt.Errorf("streaming is not supported by the current connection"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		ctx := &functions.EvaluationContext{Vars: vars, Req: r}
+		for pass := 0; len(events) > 0 && (repeat < 0 || pass <= repeat); pass++ {
+			for _, e := range events {
+				if e.delay > 0 {
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(e.delay):
+					}
+				}
+				b, err := e.format(ctx)
+				if err != nil {
+					// headers are already sent, so the stream is just interrupted
+					log.Printf("could not evaluate event: %v\n", err)
+					return
+				}
+				if _, err := w.Write(b); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+		<-r.Context().Done()
+	}, nil
+}
+
+// parseOptional parses the specified expression, unless it is empty.
+func parseOptional(expression string, parse functions.ExpressionParser) (functions.Expression, error) {
+	if expression == "" {
+		return nil, nil
+	}
+	return parse(expression)
+}
+
+// format evaluates the event and encodes it by the text/event-stream format.
+func (e *sseEvent) format(ctx *functions.EvaluationContext) ([]byte, error) {
+	var b bytes.Buffer
+	fields := []struct {
+		name string
+		e    functions.Expression
+	}{{"id", e.id}, {"event", e.event}, {"data", e.data}}
+	for _, f := range fields {
+		if f.e == nil {
+			continue
+		}
+		v, err := f.e.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s, err := sseValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if f.name != "data" {
+			// line breaks would terminate the field
+			s = strings.NewReplacer("\r", "", "\n", "").Replace(s)
+		}
+		for _, line := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n") {
+			fmt.Fprintf(&b, "%s: %s\n", f.name, line)
+		}
+	}
+	if e.retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.retry)
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// sseValue converts an evaluated field into text: values other than strings are encoded as JSON.
+func sseValue(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+func TestSSEEvents(t *testing.T) {
+	defs := []*cfg.MatchDef{{
+		RuleExpression: `${eq(request_url_path(), "/events")}`,
+		Response: map[string]interface{}{"sse": map[string]interface{}{
+			"events": []interface{}{
+				map[string]interface{}{"id": "1", "event": "greeting", "data": "hello\nworld", "retry": 500},
+				map[string]interface{}{"data": `${var("user")}`, "delay": 10},
+			},
+			"repeat": 1,
+		}},
+	}}
+	router, err := NewRouterHandler(&cfg.Config{Defs: defs, Vars: map[string]interface{}{"user": "john"}})
+	if err != nil {
+		t.Fatalf("cannot create a new instance of NewRouterHandler: %v", err)
+	}
+	server := httptest.NewServer(router)
+	defer server.Close()
+	rsp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	if s := rsp.Header.Get("Content-Type"); s != "text/event-stream" {
+		t.Errorf("expected content type 'text/event-stream'; got '%s' instead", s)
+	}
+	const event = "id: 1\nevent: greeting\ndata: hello\ndata: world\nretry: 500\n\ndata: john\n\n"
+	const expected = event + event
+	b := make([]byte, len(expected))
+	if _, err := io.ReadFull(rsp.Body, b); err != nil {
+		t.Fatalf("could not read events: %v", err)
+	}
+	if s := string(b); s != expected {
+		t.Errorf("expected events '%s'; got '%s' instead", expected, s)
+	}
+}
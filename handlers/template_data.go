@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/naighes/imposter/functions"
+)
+
+// templateData is the data response templates are executed against.
+type templateData struct {
+	Method  string
+	URL     string
+	Path    string
+	Host    string
+	Query   map[string]string
+	Headers map[string]string
+	Body    string
+	JSON    interface{}
+	Params  map[string]string
+	Vars    map[string]interface{}
+}
+
+func newTemplateData(ctx *functions.EvaluationContext) (*templateData, error) {
+	r := ctx.Req
+	d := &templateData{
+		Method:  r.Method,
+		URL:     r.URL.String(),
+		Path:    r.URL.Path,
+		Host:    r.Host,
+		Query:   make(map[string]string),
+		Headers: make(map[string]string),
+		Params:  requestParams(r),
+		Vars:    ctx.Vars,
+	}
+	for k := range r.URL.Query() {
+		d.Query[k] = r.URL.Query().Get(k)
+	}
+	for k := range r.Header {
+		d.Headers[k] = r.Header.Get(k)
+	}
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		d.Body = string(b)
+		json.Unmarshal(b, &d.JSON)
+	}
+	return d, nil
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/naighes/imposter/cfg"
+	"github.com/naighes/imposter/functions"
+)
+
+// webSocketCloseTimeout is how long the closing handshake is waited for, once a close message is sent.
+const webSocketCloseTimeout = time.Second
+
+// any origin is accepted, since clients are expected to reach a mock from wherever they are served
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+type webSocketReply struct {
+	match functions.Expression
+	send  []functions.Expression
+	close *cfg.WebSocketClose
+}
+
+type webSocketPush struct {
+	message  functions.Expression
+	interval time.Duration
+	count    int
+}
+
+type webSocketHTTPHandler struct {
+	content *cfg.WebSocketRsp
+	vars    map[string]interface{}
+}
+
+func (h webSocketHTTPHandler) handleFunc(parse functions.ExpressionParser) (func(http.ResponseWriter, *http.Request), error) {
+	onConnect, err := cfg.ParseMessages(h.content.OnConnect, parse)
+	if err != nil {
+		return nil, err
+	}
+	var replies []*webSocketReply
+	for _, reply := range h.content.Replies {
+		if reply == nil {
+			continue
+		}
+		match, err := parse(reply.Match)
+		if err != nil {
+			return nil, err
+		}
+		send, err := cfg.ParseMessages(reply.Send, parse)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, &webSocketReply{match: match, send: send, close: reply.Close})
+	}
+	var pushes []*webSocketPush
+	for _, push := range h.content.Pushes {
+		if push == nil {
+			continue
+		}
+		if push.Interval <= 0 {
+			return nil, fmt.Errorf("interval requires a value greater than zero")
+		}
+		message, err := parse(push.Message)
+		if err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, &webSocketPush{message: message, interval: push.Interval * time.Millisecond, count: push.Count})
+	}
+	closing := h.content.Close
+	vars := h.vars
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already answered the request
+			return
+		}
+		s := &webSocketSession{conn: conn, vars: vars, req: r, done: make(chan struct{})}
+		defer s.stop()
+		if !s.send(s.context(), onConnect) {
+			return
+		}
+		for _, push := range pushes {
+			go s.push(push)
+		}
+		if closing != nil {
+			go s.closeAfter(closing)
+		}
+		s.serve(replies)
+	}, nil
+}
+
+// webSocketSession runs the script of a single connection: writes are serialized, since
+// connections support just one concurrent writer.
+type webSocketSession struct {
+	conn     *websocket.Conn
+	vars     map[string]interface{}
+	req      *http.Request
+	lock     sync.Mutex
+	closed   bool
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func (s *webSocketSession) context() *functions.EvaluationContext {
+	return &functions.EvaluationContext{Vars: s.vars, Req: s.req, Params: make(map[string]string)}
+}
+
+// serve answers incoming messages by replies, until the connection is closed.
+func (s *webSocketSession) serve(replies []*webSocketReply) {
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		ctx := s.context()
+		ctx.Message = string(message)
+		// the message replaces the body of a copy of the request, so that it can be matched by body_matches_json
+		ctx.Req = s.req.WithContext(s.req.Context())
+		ctx.Req.Body = ioutil.NopCloser(bytes.NewReader(message))
+		for _, reply := range replies {
+			ok, err := reply.match.Evaluate(ctx)
+			if err != nil {
+				log.Printf("could not evaluate websocket reply: %v\n", err)
+				continue
+			}
+			if ok != true {
+				continue
+			}
+			if s.send(ctx, reply.send) && reply.close != nil {
+				go s.closeAfter(reply.close)
+			}
+			break
+		}
+	}
+}
+
+// push sends a message periodically, until the connection is closed or the count is reached.
+func (s *webSocketSession) push(p *webSocketPush) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for i := 0; p.count == 0 || i < p.count; i++ {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		if !s.send(s.context(), []functions.Expression{p.message}) {
+			return
+		}
+	}
+}
+
+// send evaluates and writes the specified messages; the connection is closed by an internal error
+// whether any of them cannot be evaluated.
+func (s *webSocketSession) send(ctx *functions.EvaluationContext, messages []functions.Expression) bool {
+	for _, e := range messages {
+		m, err := e.Evaluate(ctx)
+		if err != nil {
+			s.close(websocket.CloseInternalServerErr, err.Error())
+			return false
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			return false
+		}
+		err = s.conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("%v", m)))
+		s.lock.Unlock()
+		if err != nil {
+			s.stop()
+			return false
+		}
+	}
+	return true
+}
+
+// closeAfter closes the connection as specified by c, unless it is closed in the meantime.
+func (s *webSocketSession) closeAfter(c *cfg.WebSocketClose) {
+	select {
+	case <-s.done:
+	case <-time.After(c.After * time.Millisecond):
+		s.close(c.Code, c.Reason)
+	}
+}
+
+// close starts the closing handshake, which is waited for by the reading loop up to webSocketCloseTimeout.
+func (s *webSocketSession) close(code int, reason string) {
+	if code == 0 {
+		code = cfg.DefaultWebSocketCloseCode
+	}
+	// control frames are limited to 125 bytes, two of them carrying the code
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	deadline := time.Now().Add(webSocketCloseTimeout)
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	s.conn.SetReadDeadline(deadline)
+}
+
+// stop releases the connection along with the goroutines serving it.
+func (s *webSocketSession) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/naighes/imposter/cfg"
+)
+
+func dialWebSocket(t *testing.T, ws map[string]interface{}) (*websocket.Conn, func()) {
+	defs := []*cfg.MatchDef{{
+		RuleExpression: `${eq(request_url_path(), "/ws")}`,
+		Response:       map[string]interface{}{"websocket": ws},
+	}}
+	router, err := NewRouterHandler(&cfg.Config{Defs: defs, Vars: map[string]interface{}{"user": "john"}})
+	if err != nil {
+		t.Fatalf("cannot create a new instance of NewRouterHandler: %v", err)
+	}
+	server := httptest.NewServer(router)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("could not connect: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func readWebSocket(t *testing.T, conn *websocket.Conn, expected string) {
+	_, b, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected message '%s'; got %v instead", expected, err)
+	}
+	if s := string(b); s != expected {
+		t.Errorf("expected message '%s'; got '%s' instead", expected, s)
+	}
+}
+
+func TestWebSocketReplies(t *testing.T) {
+	conn, done := dialWebSocket(t, map[string]interface{}{
+		"on_connect": []interface{}{`${var("user")}`},
+		"replies": []interface{}{
+			map[string]interface{}{
+				"match": `${eq(websocket_message(), "ping")}`,
+				"send":  []interface{}{"pong"},
+			},
+			map[string]interface{}{
+				"match": `${body_matches_json("{\"type\": \"json\"}")}`,
+				"send":  []interface{}{"json"},
+			},
+			map[string]interface{}{
+				"match": `${eq(websocket_message(), "bye")}`,
+				"send":  []interface{}{"see you"},
+				"close": map[string]interface{}{"code": 4000, "reason": "done"},
+			},
+		},
+	})
+	defer done()
+	readWebSocket(t, conn, "john")
+	conn.WriteMessage(websocket.TextMessage, []byte("unknown"))
+	conn.WriteMessage(websocket.TextMessage, []byte("ping"))
+	readWebSocket(t, conn, "pong")
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "json", "id": 1}`))
+	readWebSocket(t, conn, "json")
+	conn.WriteMessage(websocket.TextMessage, []byte("bye"))
+	readWebSocket(t, conn, "see you")
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, 4000) {
+		t.Fatalf("expected close code 4000; got %v instead", err)
+	}
+	if e := err.(*websocket.CloseError); e.Text != "done" {
+		t.Errorf("expected close reason 'done'; got '%s' instead", e.Text)
+	}
+}
+
+func TestWebSocketPushes(t *testing.T) {
+	conn, done := dialWebSocket(t, map[string]interface{}{
+		"pushes": []interface{}{map[string]interface{}{"message": "tick", "interval": 10, "count": 3}},
+		"close":  map[string]interface{}{"after": 200},
+	})
+	defer done()
+	for i := 0; i < 3; i++ {
+		readWebSocket(t, conn, "tick")
+	}
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("expected a normal closure; got %v instead", err)
+	}
+}
@@ -0,0 +1,393 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/naighes/imposter/cfg"
+	"github.com/naighes/imposter/functions"
+)
+
+var (
+	varPrefixPattern  = regexp.MustCompile(`var\(\s*"[^"]*$`)
+	varOpeningPattern = regexp.MustCompile(`var\(\s*"$`)
+	positionPattern   = regexp.MustCompile(`:(\d+):(\d+): `)
+	linePattern       = regexp.MustCompile(`line (\d+)`)
+)
+
+// Server is a Language Server Protocol implementation for imPOSTer configuration files.
+// It provides diagnostics, completion of built-in functions and variables, hover signatures
+// and go-to-definition for variables.
+type Server struct {
+	Name    string
+	Version string
+
+	conn     *conn
+	docs     map[string]string
+	shutdown bool
+}
+
+// NewServer builds a new Server communicating over the specified streams (e.g. stdin and stdout).
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: newConn(r, w), docs: make(map[string]string)}
+}
+
+// Serve processes incoming messages until the client asks the server to exit or the input stream is closed.
+func (s *Server) Serve() error {
+	for {
+		m, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if m == nil {
+				return err
+			}
+			if err := s.conn.replyError(nil, parseError, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if m.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit notification received before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(m); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(m *message) error {
+	var result interface{}
+	var err error
+	switch m.Method {
+	case "initialize":
+		result = s.initialize()
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var p didOpenTextDocumentParams
+		if err = unmarshalParams(m, &p); err == nil {
+			s.docs[p.TextDocument.URI] = p.TextDocument.Text
+			return s.publishDiagnostics(p.TextDocument.URI)
+		}
+	case "textDocument/didChange":
+		var p didChangeTextDocumentParams
+		if err = unmarshalParams(m, &p); err == nil {
+			if l := len(p.ContentChanges); l > 0 {
+				s.docs[p.TextDocument.URI] = p.ContentChanges[l-1].Text
+			}
+			return s.publishDiagnostics(p.TextDocument.URI)
+		}
+	case "textDocument/didClose":
+		var p didCloseTextDocumentParams
+		if err = unmarshalParams(m, &p); err == nil {
+			delete(s.docs, p.TextDocument.URI)
+			return s.conn.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []diagnostic{}})
+		}
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err = unmarshalParams(m, &p); err == nil {
+			result = s.completion(&p)
+		}
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err = unmarshalParams(m, &p); err == nil {
+			if h := s.hover(&p); h != nil {
+				result = h
+			}
+		}
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err = unmarshalParams(m, &p); err == nil {
+			if l := s.definition(&p); l != nil {
+				result = l
+			}
+		}
+	default:
+		if m.ID != nil {
+			return s.conn.replyError(m.ID, methodNotFound, fmt.Errorf("method '%s' is not supported", m.Method))
+		}
+		return nil
+	}
+	if m.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return s.conn.replyError(m.ID, invalidParams, err)
+	}
+	return s.conn.reply(m.ID, result)
+}
+
+func unmarshalParams(m *message, v interface{}) error {
+	if m.Params == nil {
+		return fmt.Errorf("missing params for method '%s'", m.Method)
+	}
+	return json.Unmarshal(*m.Params, v)
+}
+
+func (s *Server) initialize() *initializeResult {
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:   syncFull,
+			CompletionProvider: completionOptions{TriggerCharacters: []string{"{", "(", ",", "\""}},
+			HoverProvider:      true,
+			DefinitionProvider: true,
+		},
+		ServerInfo: serverInfo{Name: s.Name, Version: s.Version},
+	}
+}
+
+func (s *Server) publishDiagnostics(uri string) error {
+	return s.conn.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: uri, Diagnostics: s.diagnostics(uri)})
+}
+
+// diagnostics parses and type-checks the specified document.
+// Only the errors raised by the rules of the document itself are reported, even if it includes other files.
+func (s *Server) diagnostics(uri string) []diagnostic {
+	r := []diagnostic{}
+	text := s.docs[uri]
+	lines := strings.Split(text, "\n")
+	path := uriToPath(uri)
+	config, err := cfg.ParseConfig(path, []byte(text))
+	if e, ok := err.(*cfg.DiagnosticError); ok {
+		for _, d := range e.Diagnostics {
+			r = append(r, newDiagnostic(lines, d.Line, d.Column, d.Message))
+		}
+		return r
+	}
+	if err != nil {
+		line, column := errorPosition(err)
+		return append(r, newDiagnostic(lines, line, column, err.Error()))
+	}
+	for _, d := range config.Validate(functions.ParseExpression) {
+		if filepath.Clean(d.File) != filepath.Clean(path) {
+			continue
+		}
+		message := d.Message
+		if d.Field != "" {
+			message = fmt.Sprintf("%s: %s", d.Field, d.Message)
+		}
+		r = append(r, newDiagnostic(lines, d.Line, d.Column, message))
+	}
+	return r
+}
+
+// newDiagnostic builds a diagnostic spanning from the specified 1-based position to the end of its line.
+func newDiagnostic(lines []string, line int, column int, message string) diagnostic {
+	start := position{Line: max(line-1, 0), Character: max(column-1, 0)}
+	end := start
+	if start.Line < len(lines) {
+		text := strings.TrimRight(lines[start.Line], "\r")
+		start.Character = characterOffset(text, column)
+		end.Character = max(utf16Length(text), start.Character)
+	}
+	return diagnostic{Range: textRange{Start: start, End: end}, Severity: severityError, Source: "imposter", Message: message}
+}
+
+func errorPosition(err error) (int, int) {
+	if m := positionPattern.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		column, _ := strconv.Atoi(m[2])
+		return line, column
+	}
+	if m := linePattern.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line, 1
+	}
+	return 1, 1
+}
+
+func (s *Server) completion(p *textDocumentPositionParams) []completionItem {
+	r := []completionItem{}
+	line := s.line(p.TextDocument.URI, p.Position.Line)
+	prefix := line[:byteOffset(line, p.Position.Character)]
+	if varPrefixPattern.MatchString(prefix) {
+		config, err := cfg.ParseConfig(uriToPath(p.TextDocument.URI), []byte(s.docs[p.TextDocument.URI]))
+		if err != nil {
+			return r
+		}
+		for name, value := range config.Vars {
+			r = append(r, completionItem{Label: name, Kind: completionKindVariable, Detail: fmt.Sprintf("%v", value)})
+		}
+		return r
+	}
+	for _, b := range functions.Builtins() {
+		r = append(r, completionItem{
+			Label:         b.Name,
+			Kind:          completionKindFunction,
+			Detail:        b.Signature,
+			Documentation: &markupContent{Kind: markupKindMarkdown, Value: b.Description},
+		})
+	}
+	return r
+}
+
+func (s *Server) hover(p *textDocumentPositionParams) *hover {
+	line := s.line(p.TextDocument.URI, p.Position.Line)
+	word, start, end := wordAt(line, byteOffset(line, p.Position.Character))
+	if word == "" {
+		return nil
+	}
+	rng := &textRange{
+		Start: position{Line: p.Position.Line, Character: utf16Length(line[:start])},
+		End:   position{Line: p.Position.Line, Character: utf16Length(line[:end])},
+	}
+	if varOpeningPattern.MatchString(line[:start]) {
+		config, err := cfg.ParseConfig(uriToPath(p.TextDocument.URI), []byte(s.docs[p.TextDocument.URI]))
+		if err != nil {
+			return nil
+		}
+		if v, ok := config.Vars[word]; ok {
+			return &hover{Contents: markupContent{Kind: markupKindMarkdown, Value: fmt.Sprintf("`%s` = `%v`", word, v)}, Range: rng}
+		}
+		return nil
+	}
+	if b, ok := functions.LookupBuiltin(word); ok && strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), "(") {
+		return &hover{Contents: markupContent{Kind: markupKindMarkdown, Value: fmt.Sprintf("```\n%s\n```\n%s", b.Signature, b.Description)}, Range: rng}
+	}
+	return nil
+}
+
+func (s *Server) definition(p *textDocumentPositionParams) *location {
+	line := s.line(p.TextDocument.URI, p.Position.Line)
+	word, start, _ := wordAt(line, byteOffset(line, p.Position.Character))
+	if word == "" || !varOpeningPattern.MatchString(line[:start]) {
+		return nil
+	}
+	config, err := cfg.ParseConfig(uriToPath(p.TextDocument.URI), []byte(s.docs[p.TextDocument.URI]))
+	if err != nil {
+		return nil
+	}
+	pos, ok := config.VarPosition(word)
+	if !ok || pos.Line == 0 {
+		return nil
+	}
+	uri := pathToURI(pos.File)
+	at := position{Line: pos.Line - 1, Character: characterOffset(s.fileLine(uri, pos.Line-1), pos.Column)}
+	return &location{URI: uri, Range: textRange{Start: at, End: at}}
+}
+
+func (s *Server) line(uri string, index int) string {
+	lines := strings.Split(s.docs[uri], "\n")
+	if index < 0 || index >= len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[index], "\r")
+}
+
+// fileLine returns the specified line of a document, which is read from disk unless it is open.
+func (s *Server) fileLine(uri string, index int) string {
+	if _, ok := s.docs[uri]; ok {
+		return s.line(uri, index)
+	}
+	b, err := ioutil.ReadFile(uriToPath(uri))
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(b), "\n")
+	if index < 0 || index >= len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[index], "\r")
+}
+
+// LSP positions count UTF-16 code units, while configuration positions count characters (i.e. code points)
+// and lines are indexed by bytes.
+
+// utf16Length returns the number of UTF-16 code units encoding s.
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Units(r)
+	}
+	return n
+}
+
+// characterOffset converts a 1-based column, counted in characters, into an offset in UTF-16 code units;
+// columns past the end of line are assumed to be single units.
+func characterOffset(line string, column int) int {
+	runes := []rune(line)
+	n := min(max(column-1, 0), len(runes))
+	return utf16Length(string(runes[:n])) + max(column-1-n, 0)
+}
+
+// byteOffset converts an offset in UTF-16 code units into a byte offset within line.
+func byteOffset(line string, character int) int {
+	n := 0
+	for i, r := range line {
+		if n >= character {
+			return i
+		}
+		n += utf16Units(r)
+	}
+	return len(line)
+}
+
+// utf16Units returns the number of UTF-16 code units encoding r: characters out of the basic
+// multilingual plane are encoded by surrogate pairs.
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// wordAt returns the identifier surrounding the specified character, along with its boundaries.
+func wordAt(line string, character int) (string, int, int) {
+	isIdent := func(c byte) bool {
+		return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+	}
+	if character > len(line) {
+		character = len(line)
+	}
+	start := character
+	for start > 0 && isIdent(line[start-1]) {
+		start--
+	}
+	end := character
+	for end < len(line) && isIdent(line[end]) {
+		end++
+	}
+	return line[start:end], start, end
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
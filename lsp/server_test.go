@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///tmp/imposter/config.yaml"
+
+const testDoc = `pattern_list:
+- rule_expression: ${eq(request_url_path(), var("path"))}
+  response:
+    status_code: ${"200"}
+vars:
+  path: /hello
+`
+
+func frame(method string, id int, params interface{}) string {
+	m := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		m["id"] = id
+	}
+	b, _ := json.Marshal(m)
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func readMessages(t *testing.T, out *bytes.Buffer) []*message {
+	var r []*message
+	c := newConn(out, nil)
+	for {
+		m, err := c.read()
+		if err == io.EOF {
+			return r
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = append(r, m)
+	}
+}
+
+func TestServeDiagnostics(t *testing.T) {
+	in := strings.Join([]string{
+		frame("initialize", 1, map[string]interface{}{}),
+		frame("textDocument/didOpen", 0, map[string]interface{}{"textDocument": map[string]interface{}{"uri": testURI, "text": testDoc}}),
+		frame("shutdown", 2, nil),
+		frame("exit", 0, nil),
+	}, "")
+	var out bytes.Buffer
+	s := NewServer(strings.NewReader(in), &out)
+	if err := s.Serve(); err != nil {
+		t.Error(err)
+		return
+	}
+	messages := readMessages(t, &out)
+	if l := len(messages); l != 3 {
+		t.Errorf("expected %d message(s); got %d instead", 3, l)
+		return
+	}
+	if messages[1].Method != "textDocument/publishDiagnostics" {
+		t.Errorf("expected diagnostics to be published; got '%s' instead", messages[1].Method)
+		return
+	}
+	var p publishDiagnosticsParams
+	json.Unmarshal(*messages[1].Params, &p)
+	if l := len(p.Diagnostics); l != 1 {
+		t.Errorf("expected %d diagnostic(s); got %d instead", 1, l)
+		return
+	}
+	if r := p.Diagnostics[0].Range.Start; r.Line != 3 || r.Character != 17 {
+		t.Errorf("expected diagnostic at 3:17; got %d:%d instead", r.Line, r.Character)
+		return
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.docs[testURI] = testDoc
+	items := s.completion(&textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: testURI}, Position: position{Line: 1, Character: 49}})
+	if l := len(items); l != 1 || items[0].Label != "path" {
+		t.Errorf("expected variable 'path' to be suggested; got %v instead", items)
+		return
+	}
+	items = s.completion(&textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: testURI}, Position: position{Line: 1, Character: 21}})
+	found := false
+	for _, i := range items {
+		found = found || i.Label == "request_url_path"
+	}
+	if !found {
+		t.Errorf("expected built-in function 'request_url_path' to be suggested")
+		return
+	}
+}
+
+func TestHover(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.docs[testURI] = testDoc
+	h := s.hover(&textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: testURI}, Position: position{Line: 1, Character: 22}})
+	if h == nil || !strings.Contains(h.Contents.Value, "eq(arg1: any, arg2: any) -> bool") {
+		t.Errorf("expected the signature of 'eq'; got %v instead", h)
+		return
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.docs[testURI] = testDoc
+	l := s.definition(&textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: testURI}, Position: position{Line: 1, Character: 50}})
+	if l == nil {
+		t.Errorf("expected a definition for variable 'path'")
+		return
+	}
+	if l.URI != testURI || l.Range.Start.Line != 5 || l.Range.Start.Character != 8 {
+		t.Errorf("expected definition at %s 5:8; got %s %d:%d instead", testURI, l.URI, l.Range.Start.Line, l.Range.Start.Character)
+		return
+	}
+}
+
+func TestUTF16Positions(t *testing.T) {
+	doc := `vars: {"é😀": 1, path: /hello}
+pattern_list:
+- rule_expression: ${eq("😀", var("path"))}
+  response: {body: "😀", status_code: '${"200"}'}
+`
+	s := NewServer(nil, nil)
+	s.docs[testURI] = doc
+	d := s.diagnostics(testURI)
+	if l := len(d); l != 1 {
+		t.Errorf("expected %d diagnostic(s); got %d instead", 1, l)
+		return
+	}
+	// '😀' is encoded by two UTF-16 code units
+	if r := d[0].Range; r.Start.Line != 3 || r.Start.Character != 38 || r.End.Character != 49 {
+		t.Errorf("expected diagnostic from 3:38 to 3:49; got %d:%d to %d:%d instead", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+		return
+	}
+	h := s.hover(&textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: testURI}, Position: position{Line: 2, Character: 31}})
+	if h == nil || h.Range == nil || h.Range.Start.Character != 30 || h.Range.End.Character != 33 {
+		t.Errorf("expected the hover range of 'var' to be 30-33; got %v instead", h)
+		return
+	}
+	l := s.definition(&textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: testURI}, Position: position{Line: 2, Character: 36}})
+	if l == nil || l.Range.Start.Line != 0 || l.Range.Start.Character != 23 {
+		t.Errorf("expected definition at 0:23; got %v instead", l)
+		return
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/naighes/imposter/lsp"
+)
+
+func lspCmd() command {
+	fs := flag.NewFlagSet("imposter lsp", flag.ExitOnError)
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return lspExec()
+	}}
+}
+
+func lspExec() error {
+	s := lsp.NewServer(os.Stdin, os.Stdout)
+	s.Name = ProductName
+	s.Version = Version
+	return s.Serve()
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+
+	"github.com/naighes/imposter/functions"
 )
 
 func main() {
@@ -12,10 +14,14 @@ func main() {
 		"start":    startCmd(),
 		"version":  versionCmd(),
 		"validate": validateCmd(),
+		"lsp":      lspCmd(),
+		"schema":   schemaCmd(),
+		"explain":  explainCmd(),
 	}
 	fs := flag.NewFlagSet("imposter", flag.ExitOnError)
+	seed := fs.Int64("seed", 0, "The seed of random values generated by built-in functions and templates, so that runs are reproducible")
 	fs.Usage = func() {
-		fmt.Println("Usage: imposter <command> [command flags]")
+		fmt.Println("Usage: imposter [-seed value] <command> [command flags]")
 		for name, cmd := range commands {
 			fmt.Printf("\n%s command:\n", name)
 			cmd.fs.PrintDefaults()
@@ -23,6 +29,11 @@ func main() {
 	}
 	fs.Parse(os.Args[1:])
 	args := fs.Args()
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			functions.Seed(*seed)
+		}
+	})
 	if len(args) == 0 {
 		fs.Usage()
 		os.Exit(1)
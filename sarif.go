@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+// The subset of the Static Analysis Results Interchange Format (SARIF) 2.1.0 needed to report diagnostics.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func newSarifReport(diagnostics []*cfg.Diagnostic) *sarifReport {
+	results := make([]sarifResult, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		results = append(results, newSarifResult(d))
+	}
+	driver := sarifDriver{Name: ProductName, Version: Version, InformationURI: "https://github.com/naighes/imposter"}
+	return &sarifReport{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+func newSarifResult(d *cfg.Diagnostic) sarifResult {
+	text := d.Message
+	if d.Field != "" {
+		text = fmt.Sprintf("%s: %s", d.Field, d.Message)
+	}
+	r := sarifResult{RuleID: sarifRuleID(d.Field), Level: "error", Message: sarifMessage{Text: text}}
+	if d.File != "" {
+		l := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.File)}}}
+		if d.Line > 0 {
+			l.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+		}
+		r.Locations = []sarifLocation{l}
+	}
+	return r
+}
+
+// sarifRuleID groups diagnostics by the kind of field they were raised by (e.g. "response.headers").
+func sarifRuleID(field string) string {
+	if field == "" {
+		return "configuration"
+	}
+	e := strings.SplitN(field, ".", 3)
+	if len(e) > 2 {
+		e = e[:2]
+	}
+	return strings.Join(e, ".")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+func schemaCmd() command {
+	fs := flag.NewFlagSet("imposter schema", flag.ExitOnError)
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return schemaExec()
+	}}
+}
+
+func schemaExec() error {
+	b, err := cfg.JSONSchema()
+	if err != nil {
+		return fmt.Errorf("could not build JSON schema: %v", err)
+	}
+	fmt.Printf("%s\n", string(b))
+	return nil
+}
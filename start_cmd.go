@@ -3,9 +3,13 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,7 +17,11 @@ import (
 	"time"
 
 	"github.com/naighes/imposter/cfg"
+	"github.com/naighes/imposter/functions"
 	"github.com/naighes/imposter/handlers"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 const defaultPort int = 8080
@@ -23,11 +31,28 @@ func startCmd() command {
 	opts := startOpts{port: defaultPort}
 	fs.IntVar(&opts.port, "port", defaultPort, "The listening TCP port")
 	fs.StringVar(&opts.configFile, "config-file", "", "The configuration file")
+	fs.StringVar(&opts.configFormat, "config-format", "", "The configuration format, one of {'json', 'yaml', 'toml', 'hcl'}: detected by file extension when not specified")
 	fs.StringVar(&opts.rawTLSCertFileList, "tls-cert-file-list", "", "A comma separated list of X.509 certificates to secure communication")
 	fs.StringVar(&opts.rawTLSKeyFileList, "tls-key-file-list", "", "A comma separated list of private key files corresponding to the X.509 certificates")
+	fs.BoolVar(&opts.tlsAuto, "tls-auto", false, "Secure communication by X.509 certificates issued on the fly for any requested host by an in-memory certificate authority")
+	fs.StringVar(&opts.tlsAutoCAFile, "tls-auto-ca-file", "", "Write the PEM encoded certificate authority generated by -tls-auto to the specified file, so that clients can trust it")
+	fs.StringVar(&opts.rawTLSClientCAFileList, "tls-client-ca", "", "A comma separated list of X.509 certificate authorities TLS client certificates are verified by")
+	fs.StringVar(&opts.tlsClientAuth, "tls-client-auth", "", "The authentication of TLS clients, one of {'none', 'request', 'require'} (require when -tls-client-ca is specified, none otherwise)")
+	fs.StringVar(&opts.http2, "http2", cfg.HTTP2Auto, "The HTTP/2 mode, one of {'auto', 'off', 'h2c', 'h2'}: 'h2c' accepts cleartext HTTP/2 as well, while 'h2' rejects HTTP/1 requests")
 	fs.DurationVar(&opts.wait, "graceful-timeout", time.Second*15, "The duration for which the server gracefully wait for existing connections to finish - e.g. 15s or 1m")
 	fs.StringVar(&opts.record, "record", "", "Enable the recording of PUT requests")
-	fs.BoolVar(&opts.cors, "cors", false, "Enables the support for CORS")
+	fs.BoolVar(&opts.cors, "cors", false, "Enable the support for CORS, allowing any origin whether no policy is configured")
+	fs.BoolVar(&opts.debug, "debug", false, "Answer (and log) unmatched requests with an explanation of why no rule matched")
+	fs.StringVar(&opts.tags, "tags", "", "A comma separated list of tags: just the rules labeled by at least one of them are loaded")
+	fs.StringVar(&opts.logFormat, "log-format", "text", "The access log format, one of {'text', 'json', 'combined'}")
+	fs.StringVar(&opts.logLevel, "log-level", "info", "The minimum level of the logged requests, one of {'debug', 'info', 'warn', 'error'}")
+	fs.StringVar(&opts.logFile, "log-file", "", "Write the access log to the specified file (rotated by size) instead of the standard output")
+	fs.IntVar(&opts.logMaxSize, "log-max-size", 100, "The maximum size in megabytes of the log file before it gets rotated")
+	fs.IntVar(&opts.logMaxBackups, "log-max-backups", 0, "The maximum number of rotated log files to retain (0 retains all of them)")
+	fs.IntVar(&opts.logMaxAge, "log-max-age", 0, "The maximum number of days to retain rotated log files (0 retains all of them)")
+	fs.IntVar(&opts.logBodyLimit, "log-body-limit", 0, "The maximum number of bytes of request and response bodies to be logged (0 disables body logging)")
+	fs.StringVar(&opts.logRedactHeaders, "log-redact-headers", "Authorization,Cookie,Set-Cookie", "A comma separated list of headers whose values are redacted in the access log")
+	opts.vars.register(fs)
 	return command{fs, func(args []string) error {
 		fs.Parse(args)
 		return startExec(&opts)
@@ -35,28 +60,103 @@ func startCmd() command {
 }
 
 type startOpts struct {
-	port               int
-	configFile         string
-	wait               time.Duration
-	rawTLSCertFileList string
-	rawTLSKeyFileList  string
-	record             string
-	cors               bool
+	port                   int
+	configFile             string
+	configFormat           string
+	wait                   time.Duration
+	rawTLSCertFileList     string
+	rawTLSKeyFileList      string
+	rawTLSClientCAFileList string
+	tlsClientAuth          string
+	tlsAuto                bool
+	tlsAutoCAFile          string
+	http2                  string
+	record                 string
+	cors                   bool
+	tags                   string
+	debug                  bool
+	logFormat              string
+	logLevel               string
+	logFile                string
+	logMaxSize             int
+	logMaxBackups          int
+	logMaxAge              int
+	logBodyLimit           int
+	logRedactHeaders       string
+	vars                   varsOpts
+}
+
+func (s *startOpts) buildLogger() (handlers.Logger, error) {
+	format, err := handlers.ParseFormatter(s.logFormat)
+	if err != nil {
+		return nil, err
+	}
+	level, err := handlers.ParseLevel(s.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	var out io.Writer = os.Stdout
+	if s.logFile != "" {
+		out = &lumberjack.Logger{
+			Filename:   s.logFile,
+			MaxSize:    s.logMaxSize,
+			MaxBackups: s.logMaxBackups,
+			MaxAge:     s.logMaxAge,
+		}
+	}
+	var redact []string
+	if s.logRedactHeaders != "" {
+		redact = strings.Split(s.logRedactHeaders, ",")
+	}
+	return &handlers.StreamLogger{Out: out, Format: format, Level: level, Redact: redact}, nil
 }
 
 func (s *startOpts) buildListenAndServe(server *http.Server) (func() error, error) {
-	if s.rawTLSCertFileList != "" && s.rawTLSKeyFileList != "" {
+	cfg, err := s.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return server.ListenAndServe, nil
+	}
+	server.TLSConfig = cfg
+	return func() error {
+		return server.ListenAndServeTLS("", "")
+	}, nil
+}
+
+// buildTLSConfig returns the configuration securing communication, or nil whether TLS is not enabled.
+func (s *startOpts) buildTLSConfig() (*tls.Config, error) {
+	clientAuth, err := s.buildClientAuth()
+	if err != nil {
+		return nil, err
+	}
+	if s.tlsAutoCAFile != "" && !s.tlsAuto {
+		return nil, fmt.Errorf("-tls-auto-ca-file requires -tls-auto")
+	}
+	var cfg *tls.Config
+	if s.tlsAuto {
+		if s.rawTLSCertFileList != "" || s.rawTLSKeyFileList != "" {
+			return nil, fmt.Errorf("automatic X.509 certificates cannot be enabled along with the specified ones")
+		}
+		ca, err := newAutoCA()
+		if err != nil {
+			return nil, fmt.Errorf("could not generate a certificate authority: %v", err)
+		}
+		if s.tlsAutoCAFile != "" {
+			if err := ioutil.WriteFile(s.tlsAutoCAFile, ca.pem, 0644); err != nil {
+				return nil, fmt.Errorf("could not write the certificate authority: %v", err)
+			}
+			log.Printf("certificate authority written to %s\n", s.tlsAutoCAFile)
+		}
+		cfg = &tls.Config{GetCertificate: ca.getCertificate}
+	} else if s.rawTLSCertFileList != "" && s.rawTLSKeyFileList != "" {
 		certs := strings.Split(s.rawTLSCertFileList, ",")
 		keys := strings.Split(s.rawTLSKeyFileList, ",")
 		if len(certs) != len(keys) {
 			return nil, fmt.Errorf("the number of X.509 certificates does not match the number of keys")
 		}
-		if len(certs) == 1 {
-			return func() error {
-				return server.ListenAndServeTLS(s.rawTLSCertFileList, s.rawTLSKeyFileList)
-			}, nil
-		}
-		cfg := &tls.Config{}
+		cfg = &tls.Config{}
 		for index, cert := range certs {
 			pair, err := tls.LoadX509KeyPair(cert, keys[index])
 			if err != nil {
@@ -65,54 +165,291 @@ func (s *startOpts) buildListenAndServe(server *http.Server) (func() error, erro
 			cfg.Certificates = append(cfg.Certificates, pair)
 		}
 		cfg.BuildNameToCertificate()
-		server.TLSConfig = cfg
-		return func() error {
-			return server.ListenAndServeTLS("", "")
-		}, nil
 	}
-	return server.ListenAndServe, nil
+	if cfg == nil {
+		if clientAuth != nil {
+			return nil, fmt.Errorf("the authentication of TLS clients requires X.509 certificates to secure communication")
+		}
+		return nil, nil
+	}
+	if clientAuth != nil {
+		clientAuth(cfg)
+	}
+	return cfg, nil
 }
 
-func startExec(opts *startOpts) error {
-	config, err := cfg.ReadConfig(opts.configFile)
+// buildClientAuth returns a function configuring the authentication of TLS clients, whether enabled.
+func (s *startOpts) buildClientAuth() (func(*tls.Config), error) {
+	mode := s.tlsClientAuth
+	if mode == "" {
+		mode = "none"
+		if s.rawTLSClientCAFileList != "" {
+			mode = "require"
+		}
+	}
+	var clientAuth tls.ClientAuthType
+	switch mode {
+	case "none":
+		return nil, nil
+	case "request":
+		clientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("'%s' is not a valid client authentication mode: select one from {'none', 'request', 'require'}", mode)
+	}
+	if s.rawTLSClientCAFileList == "" {
+		return nil, fmt.Errorf("the '%s' client authentication mode requires the certificate authorities client certificates are verified by", mode)
+	}
+	pool := x509.NewCertPool()
+	for _, file := range strings.Split(s.rawTLSClientCAFileList, ",") {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate authority: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("could not load client certificate authority from %s: no PEM encoded certificates found", file)
+		}
+	}
+	return func(cfg *tls.Config) {
+		cfg.ClientAuth = clientAuth
+		cfg.ClientCAs = pool
+	}, nil
+}
+
+// configureHTTP2 applies the specified HTTP/2 mode to server, once its handler is set.
+func configureHTTP2(server *http.Server, mode string) error {
+	mode, err := cfg.ParseHTTP2Mode(mode)
 	if err != nil {
-		return fmt.Errorf("could not load configuration: %v", err)
+		return err
 	}
-	var store handlers.StoreHandler
-	if opts.record != "" {
-		store, err = handlers.NewInMemoryStoreHandler(opts.record)
+	switch mode {
+	case cfg.HTTP2Off:
+		// a non-nil map prevents HTTP/2 from being negotiated with TLS clients
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	case cfg.HTTP2H2C:
+		server.Handler = h2c.NewHandler(server.Handler, &http2.Server{})
+	case cfg.HTTP2Only:
+		h := server.Handler
+		server.Handler = h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor != 2 {
+				http.Error(w, "HTTP/2 is required", http.StatusHTTPVersionNotSupported)
+				return
+			}
+			h.ServeHTTP(w, r)
+		}), &http2.Server{})
+	}
+	return nil
+}
+
+// buildHandler builds the handler serving the rules of the specified configuration, wrapped by the configured middleware.
+// Calls to the specified gRPC services, if any, are decoded before reaching the rules. The recording store is
+// shared by every listener, while each one keeps its own router state (e.g. response sequences).
+func (s *startOpts) buildHandler(config *cfg.Config, services handlers.GRPCServices, store handlers.StoreHandler, logger handlers.Logger) (http.Handler, error) {
+	routerHandler, err := handlers.NewRouterHandler(config)
+	if err != nil {
+		return nil, err
+	}
+	routerHandler.Debug = s.debug
+	var h http.Handler = routerHandler
+	if services != nil {
+		h = &handlers.GRPCHandler{Handler: h, Services: services}
+	}
+	corsConfig := config.Cors
+	if corsConfig == nil && s.cors {
+		corsConfig = cfg.DefaultCors
+	}
+	cors, err := handlers.Cors(corsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return handlers.NewPipeline(h, config.MiddlewareOrder(), map[string]handlers.Middleware{
+		cfg.MiddlewareLogging:   handlers.Logging(logger, s.logBodyLimit),
+		cfg.MiddlewareCors:      cors,
+		cfg.MiddlewareRecording: handlers.Recording(store),
+	})
+}
+
+// buildListenerServe binds the specified listener and returns a function serving requests from it.
+// HTTPS listeners without their own certificates are secured by shared, which is built once.
+func (s *startOpts) buildListenerServe(l *cfg.ListenerConfig, server *http.Server, shared func() (*tls.Config, error)) (func() error, error) {
+	if l.Protocol == cfg.ProtocolUnix {
+		// a stale socket left by a previous instance would prevent binding
+		if fi, err := os.Lstat(l.Address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(l.Address)
+		}
+		ln, err := net.Listen("unix", l.Address)
 		if err != nil {
-			return fmt.Errorf("could not load configuration: %v", err)
+			return nil, err
+		}
+		return func() error {
+			return server.Serve(ln)
+		}, nil
+	}
+	var tlsConfig *tls.Config
+	if l.Protocol == cfg.ProtocolHTTPS {
+		var err error
+		if l.CertFile != "" {
+			pair, err := tls.LoadX509KeyPair(l.CertFile, l.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not load X.509 pair from %s/%s: %v", l.CertFile, l.KeyFile, err)
+			}
+			tlsConfig = &tls.Config{Certificates: []tls.Certificate{pair}}
+			clientAuth, err := s.buildClientAuth()
+			if err != nil {
+				return nil, err
+			}
+			if clientAuth != nil {
+				clientAuth(tlsConfig)
+			}
+		} else if tlsConfig, err = shared(); err != nil {
+			return nil, err
+		} else if tlsConfig == nil {
+			return nil, fmt.Errorf("the https protocol requires X.509 certificates: specify 'cert_file' and 'key_file', or use -tls-auto")
 		}
 	}
-	routerHandler, err := handlers.NewRouterHandler(config, store)
+	ln, err := net.Listen("tcp", l.Address)
 	if err != nil {
-		return fmt.Errorf("could not load configuration: %v", err)
+		return nil, err
 	}
-	corsHandler := handlers.CorsHandler{Enabled: opts.cors}
-	loggingHandler := handlers.LoggingHandler{Logger: &handlers.DefaultLogger{}}
-	h := handlers.CompositeHandler{NestedHandlers: []http.Handler{&loggingHandler, &corsHandler, routerHandler}}
-	listenAddr := fmt.Sprintf(":%d", opts.port)
-	server := &http.Server{
-		Addr:    listenAddr,
-		Handler: &h,
+	if tlsConfig == nil {
+		return func() error {
+			return server.Serve(ln)
+		}, nil
 	}
-	c := make(chan os.Signal, 1)
-	listenAndServe, err := opts.buildListenAndServe(server)
+	server.TLSConfig = tlsConfig.Clone()
+	return func() error {
+		return server.ServeTLS(ln, "", "")
+	}, nil
+}
+
+// loadConfig reads the configuration to be served, which is validated as a whole, exactly as by the
+// validate command: any error prevents the instance from starting.
+func (s *startOpts) loadConfig() (*cfg.Config, error) {
+	format, err := cfg.ParseFormat(s.configFormat)
+	if err != nil {
+		return nil, err
+	}
+	config, err := cfg.ReadConfigFormat(s.configFile, format)
+	if err != nil {
+		return nil, fmt.Errorf("could not load configuration: %v", err)
+	}
+	if err := s.vars.apply(config); err != nil {
+		return nil, err
+	}
+	config.FilterTags(parseTags(s.tags))
+	if d := config.Validate(functions.ParseExpression); len(d) > 0 {
+		return nil, fmt.Errorf("could not load configuration:\n%v", &cfg.DiagnosticError{Diagnostics: d})
+	}
+	return config, nil
+}
+
+func startExec(opts *startOpts) error {
+	config, err := opts.loadConfig()
 	if err != nil {
 		return err
 	}
-	log.Printf("starting imposter instance listening on port %d...\n", opts.port)
-	go func() {
-		if err := listenAndServe(); err != nil {
-			log.Fatalf("could not listen on %s: %v\n", listenAddr, err)
+	logger, err := opts.buildLogger()
+	if err != nil {
+		return err
+	}
+	var services handlers.GRPCServices
+	if config.GRPC != nil {
+		if services, err = handlers.LoadGRPCServices(config.GRPC); err != nil {
+			return fmt.Errorf("could not load gRPC services: %v", err)
+		}
+		// cleartext gRPC clients speak HTTP/2 with prior knowledge: the automatic mode becomes h2c, which
+		// applies to the default server and to the listeners not specifying their own mode
+		if opts.http2 == cfg.HTTP2Auto {
+			log.Printf("gRPC services are declared: cleartext HTTP/2 (h2c) is enabled\n")
+			opts.http2 = cfg.HTTP2H2C
 		}
-	}()
+	}
+	var store handlers.StoreHandler
+	if opts.record != "" {
+		if store, err = handlers.NewInMemoryStoreHandler(opts.record); err != nil {
+			return err
+		}
+	}
+	var servers []*http.Server
+	if len(config.Listeners) == 0 {
+		h, err := opts.buildHandler(config, services, store, logger)
+		if err != nil {
+			return fmt.Errorf("could not load configuration: %v", err)
+		}
+		listenAddr := fmt.Sprintf(":%d", opts.port)
+		server := &http.Server{
+			Addr:    listenAddr,
+			Handler: h,
+		}
+		if err := configureHTTP2(server, opts.http2); err != nil {
+			return err
+		}
+		listenAndServe, err := opts.buildListenAndServe(server)
+		if err != nil {
+			return err
+		}
+		log.Printf("starting imposter instance listening on port %d...\n", opts.port)
+		go serve(listenAndServe, listenAddr)
+		servers = append(servers, server)
+	} else {
+		var shared *tls.Config
+		var sharedErr error
+		built := false
+		sharedTLSConfig := func() (*tls.Config, error) {
+			if !built {
+				shared, sharedErr = opts.buildTLSConfig()
+				built = true
+			}
+			return shared, sharedErr
+		}
+		for _, l := range config.Listeners {
+			h, err := opts.buildHandler(config.ForListener(l), services, store, logger)
+			if err != nil {
+				return fmt.Errorf("could not load configuration: %s: %v", l.Label(), err)
+			}
+			server := &http.Server{Handler: h}
+			mode := l.HTTP2
+			if mode == "" {
+				mode = opts.http2
+			}
+			if err := configureHTTP2(server, mode); err != nil {
+				return fmt.Errorf("%s: %v", l.Label(), err)
+			}
+			listenAndServe, err := opts.buildListenerServe(l, server, sharedTLSConfig)
+			if err != nil {
+				return fmt.Errorf("%s: %v", l.Label(), err)
+			}
+			log.Printf("starting %s...\n", l.Label())
+			go serve(listenAndServe, l.Address)
+			servers = append(servers, server)
+		}
+	}
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	<-c
 	ctx, cancel := context.WithTimeout(context.Background(), opts.wait)
 	defer cancel()
-	server.Shutdown(ctx)
+	for _, server := range servers {
+		server.Shutdown(ctx)
+	}
 	log.Println("imposter is shutting down...")
 	return nil
 }
+
+// parseTags splits a comma separated list of tags, ignoring blanks around them and empty entries.
+func parseTags(s string) []string {
+	var r []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			r = append(r, tag)
+		}
+	}
+	return r
+}
+
+func serve(listenAndServe func() error, addr string) {
+	if err := listenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("could not listen on %s: %v\n", addr, err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naighes/imposter/cfg"
+	"golang.org/x/net/http2"
+)
+
+// startHTTP2 serves the protocol of requests by the specified HTTP/2 mode, over TLS whether ca is not nil.
+func startHTTP2(t *testing.T, mode string, ca *autoCA) (string, func()) {
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})}
+	if err := configureHTTP2(server, mode); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca == nil {
+		go server.Serve(ln)
+		return "http://" + ln.Addr().String(), func() { server.Close() }
+	}
+	server.TLSConfig = &tls.Config{GetCertificate: ca.getCertificate}
+	go server.ServeTLS(ln, "", "")
+	return "https://" + ln.Addr().String(), func() { server.Close() }
+}
+
+// h2cClient speaks cleartext HTTP/2 with prior knowledge.
+var h2cClient = &http.Client{Transport: &http2.Transport{
+	AllowHTTP: true,
+	DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+		return net.Dial(network, addr)
+	},
+}}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	rsp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	b, _ := ioutil.ReadAll(rsp.Body)
+	return rsp.StatusCode, string(b)
+}
+
+func TestConfigureHTTP2(t *testing.T) {
+	tests := []struct {
+		mode  string
+		http1 int
+		h2c   bool
+	}{
+		{cfg.HTTP2Auto, http.StatusOK, false},
+		{cfg.HTTP2Off, http.StatusOK, false},
+		{cfg.HTTP2H2C, http.StatusOK, true},
+		{cfg.HTTP2Only, http.StatusHTTPVersionNotSupported, true},
+	}
+	for _, test := range tests {
+		url, stop := startHTTP2(t, test.mode, nil)
+		if code, _ := get(t, http.DefaultClient, url); code != test.http1 {
+			t.Errorf("%s: expected status code %d for HTTP/1.1; got %d instead", test.mode, test.http1, code)
+		}
+		rsp, err := h2cClient.Get(url)
+		if test.h2c {
+			if err != nil {
+				t.Errorf("%s: expected cleartext HTTP/2 to be accepted; got %v instead", test.mode, err)
+			} else if b, _ := ioutil.ReadAll(rsp.Body); string(b) != "HTTP/2.0" {
+				t.Errorf("%s: expected HTTP/2.0; got '%s' instead", test.mode, b)
+			}
+		} else if err == nil {
+			t.Errorf("%s: expected cleartext HTTP/2 to be rejected", test.mode)
+		}
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+		stop()
+	}
+}
+
+func TestConfigureHTTP2TLS(t *testing.T) {
+	ca, err := newAutoCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}}
+	for mode, expected := range map[string]string{cfg.HTTP2Auto: "HTTP/2.0", cfg.HTTP2Off: "HTTP/1.1"} {
+		url, stop := startHTTP2(t, mode, ca)
+		if _, proto := get(t, client, url); proto != expected {
+			t.Errorf("%s: expected %s to be negotiated; got %s instead", mode, expected, proto)
+		}
+		client.CloseIdleConnections()
+		stop()
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"valid", "pattern_list:\n- rule_expression: ${true}\n  response:\n    body: ok\n", true},
+		{"invalid rule", "pattern_list:\n- rule_expression: ${unknown()}\n  response:\n    body: ok\n", false},
+		{"invalid fallback", "upstream: http://localhost:9999\nstrict: true\n", false},
+		{"invalid virtual host", "virtual_hosts:\n- host_patterns: ['(']\n", false},
+	}
+	dir, err := ioutil.TempDir("", "imposter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, test := range tests {
+		file := filepath.Join(dir, fmt.Sprintf("config%d.yaml", i))
+		if err := ioutil.WriteFile(file, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts := &startOpts{configFile: file}
+		if _, err := opts.loadConfig(); (err == nil) != test.valid {
+			t.Errorf("%s: expected the configuration to be accepted=%t; got %v instead", test.name, test.valid, err)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/naighes/imposter/cfg"
 	"github.com/naighes/imposter/functions"
@@ -15,7 +14,10 @@ func validateCmd() command {
 	fs := flag.NewFlagSet("imposter validate", flag.ExitOnError)
 	opts := validateOpts{}
 	fs.StringVar(&opts.configFile, "config-file", "stdin", "The configuration file")
+	fs.StringVar(&opts.configFormat, "config-format", "", "The configuration format, one of {'json', 'yaml', 'toml', 'hcl'}: detected by file extension when not specified")
 	fs.BoolVar(&opts.jsonEncoded, "json", false, "Enable JSON output instead of plain text")
+	fs.BoolVar(&opts.sarif, "sarif", false, "Enable SARIF output instead of plain text")
+	opts.vars.register(fs)
 	return command{fs, func(args []string) error {
 		fs.Parse(args)
 		return validateExec(&opts)
@@ -23,28 +25,40 @@ func validateCmd() command {
 }
 
 type validateOpts struct {
-	configFile  string
-	jsonEncoded bool
+	configFile   string
+	configFormat string
+	jsonEncoded  bool
+	sarif        bool
+	vars         varsOpts
 }
 
 func validateExec(opts *validateOpts) error {
-	var r []string
-	config, err := cfg.ReadConfig(opts.configFile)
+	if opts.jsonEncoded && opts.sarif {
+		return fmt.Errorf("-json and -sarif flags are mutually exclusive")
+	}
+	var r []*cfg.Diagnostic
+	format, err := cfg.ParseFormat(opts.configFormat)
 	if err != nil {
-		return fmt.Errorf("could not load configuration: %v", err)
+		return err
 	}
-	var vars map[string]interface{}
-	if config.Vars == nil {
-		vars = make(map[string]interface{})
+	config, err := cfg.ReadConfigFormat(opts.configFile, format)
+	if e, ok := err.(*cfg.DiagnosticError); ok {
+		r = e.Diagnostics
+	} else if err != nil {
+		return fmt.Errorf("could not load configuration: %v", err)
 	} else {
-		vars = config.Vars
+		if err := opts.vars.apply(config); err != nil {
+			return err
+		}
+		r = config.Validate(functions.ParseExpression)
 	}
-	defs := config.Defs
-	for _, def := range defs {
-		errors := def.Validate(functions.ParseExpression, vars)
-		if len(errors) > 0 {
-			r = append(r, errors...)
+	if opts.sarif {
+		bytes, _ := json.MarshalIndent(newSarifReport(r), "", "  ")
+		fmt.Printf("%s", string(bytes))
+		if len(r) > 0 {
+			os.Exit(1)
 		}
+		return nil
 	}
 	if l := len(r); l > 0 {
 		if opts.jsonEncoded {
@@ -54,7 +68,12 @@ func validateExec(opts *validateOpts) error {
 		} else {
 			const sep = "\n--------------------\n"
 			fmt.Printf("found %d errors:%s", l, sep)
-			fmt.Printf(strings.Join(r[:], sep))
+			for i, d := range r {
+				if i > 0 {
+					fmt.Print(sep)
+				}
+				fmt.Print(d.String())
+			}
 		}
 		os.Exit(1)
 	}
@@ -62,6 +81,6 @@ func validateExec(opts *validateOpts) error {
 }
 
 type errorReport struct {
-	Count  int      `json:"count"`
-	Errors []string `json:"errors"`
+	Count  int               `json:"count"`
+	Errors []*cfg.Diagnostic `json:"errors"`
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/naighes/imposter/cfg"
+)
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type varsOpts struct {
+	vars     stringList
+	varFiles stringList
+}
+
+func (o *varsOpts) register(fs *flag.FlagSet) {
+	fs.Var(&o.vars, "var", "Set a variable in the configuration (e.g. -var key=value); it can be specified multiple times")
+	fs.Var(&o.varFiles, "var-file", "Set variables in the configuration from a JSON or YAML file; it can be specified multiple times")
+}
+
+// apply overrides configuration variables: files are applied in order and -var flags take precedence over them.
+func (o *varsOpts) apply(config *cfg.Config) error {
+	for _, f := range o.varFiles {
+		vars, err := cfg.ReadVars(f)
+		if err != nil {
+			return fmt.Errorf("could not load variables: %v", err)
+		}
+		config.MergeVars(vars)
+	}
+	vars := make(map[string]interface{})
+	for _, v := range o.vars {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("'%s' is not a valid variable: expected 'key=value'", v)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	config.MergeVars(vars)
+	return nil
+}